@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	ui "github.com/ostafen/termui/v3"
+
+	"github.com/ostafen/proq/pkg/api"
+	"github.com/ostafen/proq/pkg/push"
+	"github.com/ostafen/proq/pkg/remote"
+)
+
+// serverOptions enables the endpoints served besides the Prometheus HTTP API.
+type serverOptions struct {
+	// receiveWrites accepts remote-write requests on /api/v1/write.
+	receiveWrites bool
+
+	// acceptPushes accepts Pushgateway pushes on /metrics/job/.
+	acceptPushes bool
+}
+
+// serve serves the Prometheus HTTP API over the store. Since the store is owned by
+// the main loop, requests are executed by it.
+func (app *App) serve(addr string, opts serverOptions) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("unable to serve the API: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/v1/", api.NewHandler(app.store, app.exec, app.now))
+
+	if opts.receiveWrites {
+		mux.Handle("/api/v1/write", remote.NewReceiver(app.receiveWrite))
+	}
+
+	if opts.acceptPushes {
+		// pushed metrics are ingested along with the scraped ones.
+		app.gateway = push.NewGateway()
+		mux.Handle(push.PathPrefix, app.gateway)
+	}
+
+	go func() {
+		err := http.Serve(l, mux)
+		app.exec(func() {
+			app.dash.Prompt.ShowError(fmt.Errorf("API server stopped: %w", err))
+			ui.Render(app.dash.Prompt)
+		})
+	}()
+	return nil
+}
+
+// exec runs fn on the main loop, and waits for it to complete.
+func (app *App) exec(fn func()) {
+	done := make(chan struct{})
+	app.calls <- func() {
+		defer close(done)
+		fn()
+	}
+	<-done
+}
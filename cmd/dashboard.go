@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/ostafen/proq/pkg/dashboard"
+	wg "github.com/ostafen/proq/pkg/widgets"
+)
+
+const DefaultDashboardFile = "dashboard.yaml"
+
+// openDashboard loads the dashboard file and shows its panels in place of the explorer.
+func (app *App) openDashboard(path string) error {
+	d, err := dashboard.Load(path)
+	if err != nil {
+		return err
+	}
+
+	if err := app.loadDashboard(d); err != nil {
+		return fmt.Errorf("invalid dashboard \"%s\": %w", path, err)
+	}
+
+	app.dashboardFile = path
+	app.dash.SetShowGrid(true)
+	return nil
+}
+
+// loadDashboard pins the panels declared by the dashboard to the grid.
+func (app *App) loadDashboard(d *dashboard.Dashboard) error {
+	if d.Columns > 0 {
+		if err := app.dash.Grid.SetColumns(d.Columns); err != nil {
+			return err
+		}
+	}
+
+	for i, dp := range d.Panels {
+		p, err := app.dashboardPanel(dp)
+		if err != nil {
+			return fmt.Errorf("panel %d: %w", i+1, err)
+		}
+
+		if err := app.pinPanel(p); err != nil {
+			return fmt.Errorf("panel %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func (app *App) dashboardPanel(dp dashboard.Panel) (*wg.Panel, error) {
+	thresholds := make([]wg.Threshold, len(dp.Thresholds))
+	for i, t := range dp.Thresholds {
+		color, err := wg.ParseColor(t.Color)
+		if err != nil {
+			return nil, err
+		}
+		thresholds[i] = wg.Threshold{Value: t.Value, Color: color}
+	}
+
+	title := dp.Title
+	if title == "" {
+		title = dp.Metric + dp.Query
+	}
+
+	var p *wg.Panel
+	if dp.Type == dashboard.PanelHistogram {
+		p = wg.NewHistogramPanel(title, dp.Metric)
+	} else {
+		// a metric selector is a valid query as well.
+		q := dp.Query
+		if q == "" {
+			q = dp.Metric
+		}
+		p = wg.NewQueryPanel(wg.PanelType(dp.Type), title, q, app.pollInterval, app.displayWindow)
+	}
+
+	if dp.Position != nil {
+		p.Pos = &wg.GridPos{
+			Row:    dp.Position.Row,
+			Col:    dp.Position.Col,
+			Width:  dp.Position.Width,
+			Height: dp.Position.Height,
+		}
+	}
+
+	p.SetDisplay(dp.Unit, thresholds)
+	return p, nil
+}
+
+// dashboardConfig describes the current grid of panels. Positions are only
+// recorded when some panel has been explicitly placed.
+func (app *App) dashboardConfig() *dashboard.Dashboard {
+	grid := app.dash.Grid
+
+	d := &dashboard.Dashboard{
+		Columns: grid.Columns,
+		Panels:  make([]dashboard.Panel, len(grid.Panels)),
+	}
+
+	cells := grid.Cells()
+
+	placed := false
+	for _, p := range grid.Panels {
+		placed = placed || p.Pos != nil
+	}
+
+	for i, p := range grid.Panels {
+		dp := dashboard.Panel{
+			Title:  p.Title,
+			Type:   string(p.Type),
+			Query:  p.Query,
+			Metric: p.Metric,
+			Unit:   p.Unit,
+		}
+
+		for _, t := range p.Thresholds {
+			dp.Thresholds = append(dp.Thresholds, dashboard.Threshold{
+				Value: t.Value,
+				Color: wg.ColorName(t.Color),
+			})
+		}
+
+		if placed {
+			c := cells[i]
+			dp.Position = &dashboard.Position{
+				Row:    c.Row,
+				Col:    c.Col,
+				Width:  c.Width,
+				Height: c.Height,
+			}
+		}
+		d.Panels[i] = dp
+	}
+	return d
+}
+
+func (app *App) saveDashboard(_ string, args ...string) error {
+	path := app.dashboardFile
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	if path == "" {
+		path = DefaultDashboardFile
+	}
+
+	if err := dashboard.Save(path, app.dashboardConfig()); err != nil {
+		return err
+	}
+
+	app.dashboardFile = path
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ostafen/proq/pkg/export"
+	"github.com/ostafen/proq/pkg/query"
+	"github.com/ostafen/proq/pkg/store"
+)
+
+// DefaultExportSelector selects every series.
+const DefaultExportSelector = `{__name__=~".+"}`
+
+// exportCmd exports the series matching a selector, e.g. ":export http_requests_total{code="500"} errors.csv".
+func (app *App) exportCmd(_ string, args ...string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: :export <selector> <file.csv|file.jsonl>")
+	}
+
+	// the selector may contain spaces, e.g. after the commas separating its matchers.
+	selector := strings.Join(args[:len(args)-1], " ")
+	return exportSeries(app.store, selector, args[len(args)-1])
+}
+
+func exportSeries(st *store.MetricStore, selector, path string) error {
+	vs, err := query.ParseSelector(selector)
+	if err != nil {
+		return err
+	}
+
+	_, err = export.File(path, st, vs)
+	if errors.Is(err, export.ErrNoMatch) {
+		return fmt.Errorf("no series matches \"%s\"", selector)
+	}
+	return err
+}
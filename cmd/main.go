@@ -1,31 +1,88 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"log"
-	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	ui "github.com/ostafen/termui/v3"
 
 	"github.com/ostafen/proq/pkg/metric"
+	"github.com/ostafen/proq/pkg/push"
+	"github.com/ostafen/proq/pkg/query"
+	"github.com/ostafen/proq/pkg/remote"
+	"github.com/ostafen/proq/pkg/replay"
+	"github.com/ostafen/proq/pkg/scrape"
 	"github.com/ostafen/proq/pkg/store"
 	wg "github.com/ostafen/proq/pkg/widgets"
 )
 
+type histogramView int
+
+const (
+	// histogramBars shows the latest bucket counts.
+	histogramBars histogramView = iota
+	// histogramHeatmap shows the observations made over time, bucket by bucket.
+	histogramHeatmap
+	// histogramQuantiles plots quantiles of the observations made between consecutive scrapes.
+	histogramQuantiles
+)
+
+var DefaultQuantiles = []float64{0.5, 0.9, 0.99}
+
 type App struct {
 	displayWindow time.Duration
 
-	stream *store.Stream
-	ch     chan float64
+	streams []*store.Stream
+	ch      chan store.StreamSample
 
-	metricsURL   string
+	targets      []scrape.Target
 	pollInterval time.Duration
 
+	// targetStatus holds the outcome of the last scrape of each target, in the order of targets.
+	targetStatus []wg.TargetStatus
+
+	// query is the expression being plotted, if any. Since it can't be bound
+	// to the store, it is evaluated again after each scrape.
+	query     query.Expr
+	queryText string
+
+	// selected is the metric picked from the list, if any.
+	selected *wg.MetricInfo
+
+	// histView is how classic histograms are shown.
+	histView  histogramView
+	quantiles []float64
+
+	// bucketCounts shows the number of observations in each bucket, instead of cumulative
+	// counts. When bucketWindow is set, only the observations made since then are counted.
+	bucketCounts bool
+	bucketWindow time.Duration
+
+	panelQueries map[*wg.Panel]query.Expr
+	// panelSelectors holds the parsed selectors of histogram panels.
+	panelSelectors map[*wg.Panel]*query.VectorSelector
+
+	// dashboardFile is where the grid of panels is saved by default.
+	dashboardFile string
+
+	// calls are run by the main loop, which owns the store.
+	calls chan func()
+
+	// player feeds the store with past snapshots, in place of scraping the targets.
+	player *replay.Player
+
+	// gateway holds the metrics pushed to the Pushgateway endpoint, if enabled.
+	gateway *push.Gateway
+
+	// remoteWrite forwards the scraped samples to a remote-write receiver, if any.
+	remoteWrite *remote.Queue
+
 	dash  *wg.MetricsDash
 	store *store.MetricStore
 }
@@ -38,23 +95,82 @@ func (s *App) Start() {
 
 	s.dash.Resize()
 
-	ticker := time.NewTicker(s.pollInterval)
+	tickInterval := s.pollInterval
+	if s.player != nil {
+		tickInterval = replayTickInterval
+	}
+
+	ticker := time.NewTicker(tickInterval)
 	uiEvents := ui.PollEvents()
+
+	// targets are scraped in the background, so that a slow target can't block the UI.
+	var scrapes <-chan *scrape.Result
+	if s.player == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mgr := scrape.NewManager(s.targets, s.pollInterval)
+		go mgr.Run(ctx)
+
+		scrapes = mgr.Results()
+	}
+
+	lastTick := time.Now()
 	for {
 		select {
-		case <-ticker.C:
-			s.fetch()
+		case now := <-ticker.C:
+			if s.player != nil {
+				s.replay(now.Sub(lastTick))
+			} else {
+				s.scrapePushes()
+				s.showStatus()
+			}
+			lastTick = now
+
+			s.refresh()
+		case res := <-scrapes:
+			s.handleScrape(res)
 		case e := <-uiEvents:
 			s.handleUIEvent(e)
+		case fn := <-s.calls:
+			fn()
 		case v := <-s.ch:
-			s.dash.Plot.Update(v)
+			s.dash.Plot.Update(v.Line, v.Sample)
+			s.dash.RenderExplorer(s.dash.Plot)
 		}
 	}
 }
 
+// refresh renders the views which are updated after each scrape.
+func (app *App) refresh() {
+	now := app.now()
+
+	if app.dash.ShowGrid {
+		app.renderPanels(now)
+	}
+
+	switch {
+	case app.query != nil:
+		app.renderQuery(now)
+	case app.refreshHistogram():
+	default:
+		app.dash.Plot.Advance(now)
+		app.dash.RenderExplorer(app.dash.Plot)
+	}
+}
+
 func (app *App) handleUIEvent(e ui.Event) {
 	switch e.Type {
 	case ui.KeyboardEvent:
+		if app.player != nil && app.onReplayKeyPressed(e.ID) {
+			return
+		}
+
+		if err := app.onKeyPressed(e.ID); err != nil {
+			app.dash.Prompt.ShowError(err)
+			ui.Render(app.dash.Prompt)
+			return
+		}
 		app.dash.OnKeyPressed(e.ID)
 	case ui.ResizeEvent:
 		app.dash.Resize()
@@ -62,10 +178,20 @@ func (app *App) handleUIEvent(e ui.Event) {
 }
 
 func (app *App) renderMetric(m wg.MetricInfo) {
-	if app.stream != nil {
-		app.stream.Close()
-		close(app.ch)
-		app.ch = make(chan float64, 1)
+	app.query = nil
+	app.selected = &m
+	app.dash.Plot.Rate = false
+
+	app.renderSelected()
+}
+
+// renderSelected renders the selected metric from scratch, binding it to the store again.
+func (app *App) renderSelected() {
+	app.unbind()
+
+	m := app.selected
+	if m == nil {
+		return
 	}
 
 	mk := metric.MetricKey{
@@ -73,44 +199,267 @@ func (app *App) renderMetric(m wg.MetricInfo) {
 		Labels: m.Labels,
 	}
 
-	if m.IsHist {
-		app.renderHistogram(mk)
-	} else {
-		app.renderGenericMetric(mk)
+	switch m.Kind {
+	case wg.KindHistogram:
+		app.renderHistogram(mk, m.Title())
+	case wg.KindNativeHistogram:
+		app.renderNativeHistogram(mk, m.Title())
+	case wg.KindSummary:
+		app.renderSummary(mk, m.Title())
+	default:
+		app.renderGenericMetric(mk, m.Title())
+	}
+}
+
+func (app *App) renderNativeHistogram(m metric.MetricKey, title string) {
+	h, ok := app.store.LookupNativeHist(m)
+	if !ok {
+		app.renderMissing(title)
+		return
+	}
+
+	width, height := ui.TerminalDimensions()
+
+	app.dash.Hist = wg.NewNativeHistogram(h, width)
+	app.dash.Hist.Title = title
+
+	app.dash.Hist.SetRect(0, 0, width, int(float64(height)*0.7))
+	app.dash.RenderExplorer(app.dash.Hist.BarChart)
+}
+
+// refreshHistogram renders the selected histogram again, so that it reflects the latest scrape.
+// It returns false if the selected metric is not a histogram.
+func (app *App) refreshHistogram() bool {
+	m := app.selected
+	if m == nil {
+		return false
+	}
+
+	switch m.Kind {
+	case wg.KindHistogram:
+		app.renderHistogram(m.Key(), m.Title())
+	case wg.KindNativeHistogram:
+		app.renderNativeHistogram(m.Key(), m.Title())
+	default:
+		return false
 	}
+	return true
 }
 
-func (app *App) renderHistogram(m metric.MetricKey) {
-	h := app.store.GetHist(m)
+func (app *App) renderHistogram(m metric.MetricKey, title string) {
+	switch app.histView {
+	case histogramHeatmap:
+		app.renderHeatmap(m, title)
+		return
+	case histogramQuantiles:
+		app.renderQuantiles(m, title)
+		return
+	}
+
+	h, ok := app.store.LookupHist(m)
+	if !ok {
+		app.renderMissing(title)
+		return
+	}
 
 	width, height := ui.TerminalDimensions()
 
-	app.dash.Hist = wg.NewHistogram(h, width)
+	switch {
+	case app.bucketCounts && app.bucketWindow > 0:
+		var snapshots []metric.Histogram
+		app.store.HistogramSnapshots(m, func(h metric.Histogram) {
+			snapshots = append(snapshots, h)
+		})
+
+		bins := metric.WindowBins(snapshots, app.bucketWindow.Milliseconds())
+		app.dash.Hist = wg.NewBucketHistogram(h.Name, bins, width)
+		app.dash.Hist.Title = fmt.Sprintf("%s (last %s)", title, app.bucketWindow)
+	case app.bucketCounts:
+		app.dash.Hist = wg.NewBucketHistogram(h.Name, h.Bins, width)
+		app.dash.Hist.Title = title
+	default:
+		app.dash.Hist = wg.NewHistogram(h, width)
+		app.dash.Hist.Title = title
+	}
 
 	app.dash.Hist.SetRect(0, 0, int(float64(width)), int(float64(height)*0.7))
-	ui.Render(app.dash.Hist.BarChart)
+	app.dash.RenderExplorer(app.dash.Hist.BarChart)
+}
+
+// renderHeatmap shows the observations made by the histogram between consecutive scrapes.
+func (app *App) renderHeatmap(m metric.MetricKey, title string) {
+	var snapshots []metric.Histogram
+	app.store.HistogramSnapshots(m, func(h metric.Histogram) {
+		snapshots = append(snapshots, h)
+	})
+
+	var bounds []float64
+	if len(snapshots) > 0 {
+		for _, b := range snapshots[len(snapshots)-1].Bins {
+			bounds = append(bounds, b.Value)
+		}
+	}
+
+	heatmap := app.dash.Heatmap
+	heatmap.Title = title
+	heatmap.SetData(bounds, wg.HeatmapColumns(snapshots), app.now())
+
+	app.dash.RenderExplorer(heatmap)
 }
 
-func (app *App) renderGenericMetric(m metric.MetricKey) {
+// renderQuantiles plots a line for each of the configured quantiles.
+func (app *App) renderQuantiles(m metric.MetricKey, title string) {
+	var snapshots []metric.Histogram
+	app.store.HistogramSnapshots(m, func(h metric.Histogram) {
+		snapshots = append(snapshots, h)
+	})
+
+	lines := make([][]metric.Sample, len(app.quantiles))
+	legend := make([]string, len(app.quantiles))
+	for i, q := range app.quantiles {
+		lines[i] = metric.QuantileSamples(q, snapshots)
+		legend[i] = formatQuantile(q)
+	}
+
+	now := app.now()
+
+	plot := app.dash.Plot
+	plot.Rate = false
+	plot.SetLines(lines, legend)
+	plot.Title = title
+	plot.Advance(now)
+
+	app.dash.RenderExplorer(plot)
+}
+
+// formatQuantile formats a quantile as a percentile, e.g. 0.99 as "p99".
+func formatQuantile(q float64) string {
+	return "p" + strconv.FormatFloat(q*100, 'f', -1, 64)
+}
+
+// renderGenericMetric plots the series along with the pinned ones, each fed by its own stream.
+func (app *App) renderGenericMetric(m metric.MetricKey, title string) {
 	st := app.store
 	dash := app.dash
 
-	var samples []float64
-	n := st.Samples(m, func(f float64) {
-		samples = append(samples, f)
-	})
+	keys := []metric.MetricKey{m}
+	for _, p := range dash.List.Pinned() {
+		if pk := p.Key(); pk.String() != m.String() {
+			keys = append(keys, pk)
+		}
+	}
+
+	lines := make([][]metric.Sample, len(keys))
+	for i, key := range keys {
+		n := st.Samples(key, func(s metric.Sample) {
+			lines[i] = append(lines[i], s)
+		})
+		app.bind(key, i, n)
+	}
+
+	var legend []string
+	if len(keys) > 1 {
+		legend = make([]string, len(keys))
+		for i, key := range keys {
+			legend[i] = key.String()
+		}
+	}
+
+	dash.Plot.SetLines(lines, legend)
+	dash.Plot.Title = title
+	app.dash.RenderExplorer(app.dash.Plot)
+}
+
+// renderSummary plots each quantile of the summary as a separate line.
+func (app *App) renderSummary(m metric.MetricKey, title string) {
+	st := app.store
+	dash := app.dash
+
+	s, ok := st.LookupSummary(m)
+	if !ok {
+		app.renderMissing(title)
+		return
+	}
+
+	lines := make([][]metric.Sample, len(s.Quantiles))
+	legend := make([]string, len(s.Quantiles))
+	for i, q := range s.Quantiles {
+		key := s.QuantileKey(i)
+
+		n := st.Samples(key, func(s metric.Sample) {
+			lines[i] = append(lines[i], s)
+		})
+		app.bind(key, i, n)
+
+		legend[i] = "q" + metric.FormatFloat(q.Quantile)
+	}
+
+	dash.Plot.SetLines(lines, legend)
+	dash.Plot.Title = title
+	app.dash.RenderExplorer(app.dash.Plot)
+}
+
+// renderMissing shows an empty plot for a metric which is not in the store, such as
+// one first scraped after the time a replay was moved back to.
+func (app *App) renderMissing(title string) {
+	app.dash.Plot.SetLines(nil, nil)
+	app.dash.Plot.Title = title + " (no data)"
+	app.dash.RenderExplorer(app.dash.Plot)
+}
 
-	if len(samples) > 0 {
-		if len(dash.Plot.Data) == 0 {
-			dash.Plot.Data = [][]float64{samples}
-		} else {
-			dash.Plot.Data[0] = samples
+// renderQuery evaluates the current query over the display window and plots the resulting series.
+func (app *App) renderQuery(now time.Time) {
+	res, err := query.Eval(app.store, app.query, now.Add(-app.displayWindow), now, app.pollInterval)
+	if err != nil {
+		app.query = nil
+		app.dash.Prompt.ShowError(err)
+		ui.Render(app.dash.Prompt)
+		return
+	}
+
+	lines := make([][]metric.Sample, len(res))
+	legend := make([]string, len(res))
+	for i, s := range res {
+		lines[i] = s.Samples
+
+		legend[i] = s.Metric.String()
+		if legend[i] == "" {
+			legend[i] = app.queryText
 		}
 	}
 
-	app.stream = st.Bind(m, app.ch, n)
-	dash.Plot.Title = m.Name
-	ui.Render(app.dash.Plot)
+	app.dash.Plot.SetLines(lines, legend)
+	app.dash.Plot.Title = "Query: " + app.queryText
+	app.dash.Plot.Advance(now)
+	app.dash.RenderExplorer(app.dash.Plot)
+}
+
+// now returns the current time or, when replaying, the time of the replayed snapshots.
+func (app *App) now() time.Time {
+	if app.player != nil {
+		return app.player.Now()
+	}
+	return time.Now()
+}
+
+func (app *App) unbind() {
+	if len(app.streams) == 0 {
+		return
+	}
+
+	for _, s := range app.streams {
+		s.Close()
+	}
+	app.streams = nil
+
+	close(app.ch)
+	app.ch = make(chan store.StreamSample, streamBufferSize)
+}
+
+func (app *App) bind(m metric.MetricKey, line int, n int) {
+	if stream := app.store.Bind(m, app.ch, line, n); stream != nil {
+		app.streams = append(app.streams, stream)
+	}
 }
 
 func (app *App) cmdsHandlers() map[string]wg.CmdHandler {
@@ -119,7 +468,182 @@ func (app *App) cmdsHandlers() map[string]wg.CmdHandler {
 		"s": app.filter,
 		"t": app.filterByType,
 		"r": app.reset,
+
+		"target":    app.filterByTarget,
+		"query":     app.runQuery,
+		"rate":      app.toggleRate,
+		"heatmap":   app.toggleHeatmap,
+		"quantiles": app.setQuantiles,
+		"buckets":   app.setBucketCounts,
+		"clear":     app.clearPinned,
+
+		"panel":   app.addPanelCmd,
+		"columns": app.setColumns,
+		"save":    app.saveDashboard,
+
+		"export": app.exportCmd,
+
+		"seek":  app.seekCmd,
+		"speed": app.setSpeed,
+	}
+}
+
+func (app *App) toggleHeatmap(_ string, args ...string) error {
+	if app.histView == histogramHeatmap {
+		return app.setHistogramView(histogramBars)
+	}
+	return app.setHistogramView(histogramHeatmap)
+}
+
+// setBucketCounts shows the observations of each bucket of the selected histogram, made
+// over the given duration. When no duration is given, it toggles between per-bucket
+// and cumulative counts.
+func (app *App) setBucketCounts(_ string, args ...string) error {
+	if err := app.checkClassicHistogram(); err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		app.bucketCounts = !app.bucketCounts || app.bucketWindow > 0
+		app.bucketWindow = 0
+		return app.setHistogramView(histogramBars)
+	}
+
+	window, err := time.ParseDuration(args[0])
+	if err != nil || window <= 0 {
+		return fmt.Errorf("invalid duration \"%s\"", args[0])
 	}
+
+	app.bucketCounts = true
+	app.bucketWindow = window
+	return app.setHistogramView(histogramBars)
+}
+
+// setQuantiles plots the given quantiles of the selected histogram, or toggles
+// the quantile lines when none is given.
+func (app *App) setQuantiles(_ string, args ...string) error {
+	if len(args) == 0 {
+		if app.histView == histogramQuantiles {
+			return app.setHistogramView(histogramBars)
+		}
+		return app.setHistogramView(histogramQuantiles)
+	}
+
+	quantiles, err := parseQuantiles(args)
+	if err != nil {
+		return err
+	}
+
+	if err := app.checkClassicHistogram(); err != nil {
+		return err
+	}
+
+	app.quantiles = quantiles
+	return app.setHistogramView(histogramQuantiles)
+}
+
+// parseQuantiles parses quantiles separated by spaces or commas.
+func parseQuantiles(args []string) ([]float64, error) {
+	var quantiles []float64
+	for _, arg := range args {
+		for _, s := range strings.Split(arg, ",") {
+			if s == "" {
+				continue
+			}
+
+			q, err := strconv.ParseFloat(s, 64)
+			if err != nil || q < 0 || q > 1 {
+				return nil, fmt.Errorf("invalid quantile \"%s\": must be between 0 and 1", s)
+			}
+			quantiles = append(quantiles, q)
+		}
+	}
+
+	if len(quantiles) == 0 {
+		return nil, fmt.Errorf("no quantile given")
+	}
+	return quantiles, nil
+}
+
+func (app *App) checkClassicHistogram() error {
+	if app.selected == nil || app.selected.Kind != wg.KindHistogram {
+		return fmt.Errorf("the selected metric is not a classic histogram")
+	}
+	return nil
+}
+
+func (app *App) setHistogramView(view histogramView) error {
+	if err := app.checkClassicHistogram(); err != nil {
+		return err
+	}
+
+	app.histView = view
+
+	ui.Clear()
+	app.dash.Render()
+	app.refreshHistogram()
+	return nil
+}
+
+func (app *App) clearPinned(_ string, args ...string) error {
+	app.dash.List.ClearPinned()
+	if app.selected != nil {
+		app.renderMetric(*app.selected)
+	}
+	return nil
+}
+
+// toggleRate switches the plot of the selected counter between its raw value and its per-second rate.
+func (app *App) toggleRate(_ string, args ...string) error {
+	if app.selected == nil || !app.selected.IsCounter() {
+		return fmt.Errorf("the selected metric is not a counter")
+	}
+
+	for _, m := range app.dash.List.Pinned() {
+		if !m.IsCounter() {
+			return fmt.Errorf("pinned metric %s is not a counter", m.Name)
+		}
+	}
+
+	plot := app.dash.Plot
+
+	plot.Rate = !plot.Rate
+	plot.Title = app.selected.Title()
+	if plot.Rate {
+		plot.Title += " [rate/s]"
+	}
+
+	app.dash.RenderExplorer(plot)
+	return nil
+}
+
+func (app *App) runQuery(_ string, args ...string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no query specified")
+	}
+
+	text := strings.Join(args, " ")
+
+	e, err := query.Parse(text)
+	if err != nil {
+		return err
+	}
+
+	app.unbind()
+	app.query = e
+	app.queryText = text
+	app.selected = nil
+	app.dash.Plot.Rate = false
+
+	app.renderQuery(app.now())
+	return nil
+}
+
+func (app *App) filterByTarget(_ string, args ...string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no target specified")
+	}
+	return app.dash.FilterByLabels(args[0], scrape.JobLabel, scrape.InstanceLabel)
 }
 
 func (app *App) filterByType(_ string, args ...string) error {
@@ -127,14 +651,22 @@ func (app *App) filterByType(_ string, args ...string) error {
 		return fmt.Errorf("no type argument provided")
 	}
 
-	switch strings.ToLower(args[0]) {
-	case "all":
+	if strings.ToLower(args[0]) == "all" {
 		app.dash.ResetMetrics()
-	case "hist":
-		app.dash.ShowHistograms()
-	default:
-		return fmt.Errorf("unknown metric type\"%s\"", args[0])
+		return nil
+	}
+
+	typ := args[0]
+	if strings.ToLower(typ) == "hist" {
+		typ = string(metric.TypeHistogram)
+	}
+
+	t, err := metric.ParseMetricType(typ)
+	if err != nil {
+		return err
 	}
+
+	app.dash.ShowType(t)
 	return nil
 }
 
@@ -153,111 +685,285 @@ func (app *App) filter(_ string, args ...string) error {
 func (s *App) quit(_ string, args ...string) error {
 	ui.Close()
 
+	if s.remoteWrite != nil {
+		s.remoteWrite.Close()
+	}
+
 	os.Exit(0)
 	return nil
 }
 
-func (s *App) fetch() {
-	histo, rawMetrics, err := s.fetchMetrics(s.metricsURL)
-	if err != nil {
-		return
+// scrapePushes ingests the metrics held by the Pushgateway endpoint, as if it was a target.
+func (s *App) scrapePushes() {
+	if s.gateway != nil {
+		s.ingestScraped(s.gateway.Result(time.Now()))
+	}
+}
+
+// handleScrape records the outcome of the scrape of a target, through the status bar and
+// the up, scrape_duration_seconds, scrape_samples_scraped and scrape_parse_errors series.
+func (s *App) handleScrape(res *scrape.Result) {
+	s.setTargetStatus(res)
+	s.ingestScraped(scrape.NewResult(res.Target, res.Health(), scrape.HealthMetadata, res.Timestamp))
+
+	if res.Err == nil {
+		s.ingestScraped(res)
 	}
+}
+
+// ingestScraped ingests the result of a scrape, and forwards its samples.
+func (s *App) ingestScraped(res *scrape.Result) {
+	s.ingest(res)
 
-	for _, m := range rawMetrics {
-		s.store.Update(&m)
+	if s.remoteWrite != nil {
+		s.remoteWrite.Enqueue(res.Samples())
 	}
+}
 
-	s.store.UpdateHistograms(histo)
+func (s *App) ingest(res *scrape.Result) {
+	storeResult(s.store, res)
 
-	metrics := make([]wg.MetricInfo, len(rawMetrics)+len(histo))
-	for i, m := range rawMetrics {
-		metrics[i] = wg.MetricInfo{
-			Name:   m.Name,
-			Labels: m.Labels,
-			IsHist: false,
-		}
+	metrics := make([]wg.MetricInfo, 0, len(res.Metrics)+len(res.Histograms)+len(res.Natives)+len(res.Summaries))
+	for _, m := range res.Metrics {
+		metrics = append(metrics, s.metricInfo(m.Name, m.Labels, wg.KindSeries))
 	}
 
-	i := 0
-	for _, m := range histo {
-		metrics[len(rawMetrics)+i] = wg.MetricInfo{
-			Name:   m.Name,
-			Labels: m.Labels,
-			IsHist: true,
-		}
-		i++
+	for _, m := range res.Histograms {
+		metrics = append(metrics, s.metricInfo(m.Name, m.Labels, wg.KindHistogram))
+	}
+
+	for _, m := range res.Natives {
+		metrics = append(metrics, s.metricInfo(m.Name, m.Labels, wg.KindNativeHistogram))
+	}
+
+	for _, m := range res.Summaries {
+		metrics = append(metrics, s.metricInfo(m.Name, m.Labels, wg.KindSummary))
 	}
 
 	s.dash.SetMetricList(metrics)
 }
 
-func (s *App) fetchMetrics(url string) (map[string]metric.Histogram, []metric.RawMetric, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, nil, fmt.Errorf("error fetching metrics: %v", err)
+// ingestAll ingests the results of several scrapes at once.
+func (app *App) ingestAll(results []*scrape.Result) {
+	// bound streams would be flooded by the samples of several results,
+	// so the selected metric is rendered again from the store instead.
+	if len(results) > 1 {
+		app.unbind()
 	}
-	defer resp.Body.Close()
 
-	rawMetrics := make([]metric.RawMetric, 0, 100)
+	for _, res := range results {
+		app.ingest(res)
+	}
 
-	sc := bufio.NewScanner(resp.Body)
-	for sc.Scan() {
-		line := sc.Text()
-		if strings.HasPrefix(line, "#") {
-			continue
-		}
+	if len(results) > 1 && app.query == nil {
+		app.renderSelected()
+	}
+}
 
-		m, err := metric.ParseMetricLine(line)
-		if err != nil {
-			fmt.Printf("unable to parse line: %s\n", line)
-			continue
-		}
+func storeResult(st *store.MetricStore, res *scrape.Result) {
+	st.UpdateMetadata(res.Metadata)
 
-		rawMetrics = append(rawMetrics, m)
+	for _, m := range res.Metrics {
+		st.Update(&m)
 	}
 
-	histograms, rem := metric.ParseHistogram(rawMetrics)
-	return histograms, rem, sc.Err()
+	st.UpdateHistograms(res.Histograms)
+	st.UpdateNativeHistograms(res.Natives)
+	st.UpdateSummaries(res.Summaries)
+}
+
+func (s *App) metricInfo(name string, labels []metric.Label, kind wg.MetricKind) wg.MetricInfo {
+	mi := wg.MetricInfo{
+		Name:   name,
+		Labels: labels,
+		Kind:   kind,
+		Type:   metric.TypeUnknown,
+	}
+
+	if md, ok := s.store.Metadata(name); ok {
+		mi.Type = md.Type
+		mi.Help = md.Help
+		mi.Unit = md.Unit
+	}
+
+	switch kind {
+	case wg.KindHistogram, wg.KindNativeHistogram:
+		mi.Type = metric.TypeHistogram
+	case wg.KindSummary:
+		mi.Type = metric.TypeSummary
+	}
+	return mi
 }
 
 const (
 	DefaultDisplayWindow = time.Minute
 	DefaultPollInterval  = 1 * time.Second
+
+	// streamBufferSize bounds the samples of the bound series which
+	// can be delivered by a single scrape before being plotted.
+	streamBufferSize = 64
 )
 
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("no url specified")
-		os.Exit(1)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "record":
+			runRecord(os.Args[2:])
+			return
+		case "replay":
+			runReplay(os.Args[2:])
+			return
+		}
 	}
 
-	url := os.Args[1]
-	os.Args = os.Args[1:]
+	specs, args := splitArgs(os.Args[1:])
+	os.Args = append([]string{os.Args[0]}, args...)
 
 	displayWindow := flag.Duration("window", DefaultDisplayWindow, "time size of displayed window")
 	pollInterval := flag.Duration("poll-interval", DefaultPollInterval, "the frequency the metric endpoint is queried")
+	targetsFile := flag.String("targets", "", "file listing the targets to scrape, one per line")
+	scrapeTimeout := flag.Duration("scrape-timeout", scrape.DefaultTimeout, "timeout of the scrapes of the targets which don't set their own, capped to the poll interval")
+	dashboardFile := flag.String("dashboard", "", "YAML file declaring the panels of the dashboard")
+	exportFile := flag.String("export", "", "scrape the targets once, export the series to the given .csv or .jsonl file and exit")
+	selector := flag.String("select", DefaultExportSelector, "the series exported by --export")
+	listenAddr := flag.String("listen", "", "address to serve the Prometheus HTTP API on, e.g. \":9091\"")
+	remoteWriteURL := flag.String("remote-write-url", "", "forward the scraped samples to a remote-write receiver, e.g. \"http://localhost:9090/api/v1/write\"")
+	acceptPushes := flag.Bool("pushgateway", false, "accept Pushgateway pushes on /metrics/job/ of the --listen address")
+	receiveWrites := flag.Bool("remote-write-receiver", false, "accept remote-write requests on /api/v1/write of the --listen address")
 
 	flag.Parse()
 
-	maxSamples := int(*displayWindow/(*pollInterval)) + 1
+	targets, err := parseTargets(append(specs, flag.Args()...), *targetsFile, *scrapeTimeout)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if (*receiveWrites || *acceptPushes) && *listenAddr == "" {
+		fmt.Println("--remote-write-receiver and --pushgateway require --listen")
+		os.Exit(1)
+	}
+
+	// series can also be pushed, in place of being scraped.
+	if len(targets) == 0 && (!(*receiveWrites || *acceptPushes) || *exportFile != "") {
+		fmt.Println("no url specified")
+		os.Exit(1)
+	}
+
+	if *exportFile != "" {
+		st := store.NewMetricStore(1)
+		for _, res := range scrape.ScrapeAll(context.Background(), targets) {
+			if res.Err != nil {
+				fmt.Printf("unable to scrape %s: %s\n", res.Target.URL, res.Err)
+				os.Exit(1)
+			}
+			storeResult(st, res)
+		}
+
+		if err := exportSeries(st, *selector, *exportFile); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	app := newApp(targets, *pollInterval, *displayWindow)
+
+	if *dashboardFile != "" {
+		if err := app.openDashboard(*dashboardFile); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	if *remoteWriteURL != "" {
+		if err := app.forwardTo(*remoteWriteURL); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	if *listenAddr != "" {
+		opts := serverOptions{
+			receiveWrites: *receiveWrites,
+			acceptPushes:  *acceptPushes,
+		}
+
+		if err := app.serve(*listenAddr, opts); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	app.Start()
+}
+
+func newApp(targets []scrape.Target, pollInterval, displayWindow time.Duration) *App {
+	maxSamples := int(displayWindow/pollInterval) + 1
 	metricStore := store.NewMetricStore(maxSamples)
 
 	dash := wg.NewMetricDash(
-		*pollInterval,
-		*displayWindow,
+		pollInterval,
+		displayWindow,
 	)
 
 	app := &App{
-		displayWindow: *displayWindow,
-		pollInterval:  *pollInterval,
-		ch:            make(chan float64, 1),
-		metricsURL:    url,
-		store:         metricStore,
-		dash:          dash,
+		displayWindow:  displayWindow,
+		pollInterval:   pollInterval,
+		ch:             make(chan store.StreamSample, streamBufferSize),
+		calls:          make(chan func()),
+		targets:        targets,
+		store:          metricStore,
+		dash:           dash,
+		panelQueries:   make(map[*wg.Panel]query.Expr),
+		panelSelectors: make(map[*wg.Panel]*query.VectorSelector),
+		quantiles:      DefaultQuantiles,
+	}
+
+	app.targetStatus = make([]wg.TargetStatus, len(targets))
+	for i, t := range targets {
+		app.targetStatus[i].Name = t.Job + "/" + t.Instance
 	}
 
 	dash.List = wg.NewMetricList(app.renderMetric)
 	dash.Prompt.SetHandlers(app.cmdsHandlers())
+	return app
+}
 
-	app.Start()
+// splitArgs separates the leading targets from the flags, since targets are
+// given before flags, but are also accepted after them.
+func splitArgs(args []string) ([]string, []string) {
+	i := 0
+	for i < len(args) && !strings.HasPrefix(args[i], "-") {
+		i++
+	}
+	return args[:i], args[i:]
+}
+
+// parseTargets parses the targets given on the command line and listed in the targets file.
+// The targets which don't set their own timeout get the given one.
+func parseTargets(specs []string, targetsFile string, timeout time.Duration) ([]scrape.Target, error) {
+	var targets []scrape.Target
+	if targetsFile != "" {
+		fileTargets, err := scrape.LoadTargets(targetsFile)
+		if err != nil {
+			return nil, err
+		}
+		targets = fileTargets
+	}
+
+	for _, spec := range specs {
+		t, err := scrape.ParseTarget(spec)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+
+	for i := range targets {
+		if targets[i].Timeout == 0 {
+			targets[i].Timeout = timeout
+		}
+	}
+	return targets, nil
 }
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	ui "github.com/ostafen/termui/v3"
+
+	"github.com/ostafen/proq/pkg/metric"
+	"github.com/ostafen/proq/pkg/query"
+	wg "github.com/ostafen/proq/pkg/widgets"
+)
+
+// onKeyPressed handles the keybindings managing the grid of panels.
+func (app *App) onKeyPressed(key string) error {
+	grid := app.dash.Grid
+
+	switch key {
+	case "<C-a>":
+		return app.addPanel("")
+	case "<C-x>":
+		if !app.dash.ShowGrid {
+			return nil
+		}
+
+		if p := grid.RemoveFocused(); p != nil {
+			delete(app.panelQueries, p)
+			delete(app.panelSelectors, p)
+		}
+	case "<Tab>":
+		if !app.dash.ShowGrid {
+			return nil
+		}
+		grid.FocusNext()
+	case "<C-g>":
+		app.dash.ToggleGrid()
+		if !app.dash.ShowGrid {
+			return nil
+		}
+		app.renderPanels(app.now())
+	default:
+		return nil
+	}
+
+	if app.dash.ShowGrid {
+		app.dash.Render()
+	}
+	return nil
+}
+
+// addPanel adds the current query or selected metric to the grid. When typ is empty,
+// histograms are shown as histograms and anything else as a plot.
+func (app *App) addPanel(typ wg.PanelType) error {
+	p, err := app.newPanel(typ)
+	if err != nil {
+		return err
+	}
+
+	if err := app.pinPanel(p); err != nil {
+		return err
+	}
+	if app.dash.ShowGrid {
+		app.renderPanels(app.now())
+	}
+	return nil
+}
+
+func (app *App) pinPanel(p *wg.Panel) error {
+	if p.Query != "" {
+		e, err := query.Parse(p.Query)
+		if err != nil {
+			return err
+		}
+		app.panelQueries[p] = e
+	}
+
+	if p.Metric != "" {
+		vs, err := query.ParseSelector(p.Metric)
+		if err != nil {
+			return fmt.Errorf("invalid metric \"%s\": %w", p.Metric, err)
+		}
+		app.panelSelectors[p] = vs
+	}
+
+	app.dash.Grid.Add(p)
+	return nil
+}
+
+func (app *App) newPanel(typ wg.PanelType) (*wg.Panel, error) {
+	if app.query != nil {
+		if typ == wg.PanelHistogram {
+			return nil, fmt.Errorf("queries can't be shown as histograms")
+		}
+
+		if typ == "" {
+			typ = wg.PanelPlot
+		}
+		return wg.NewQueryPanel(typ, app.queryText, app.queryText, app.pollInterval, app.displayWindow), nil
+	}
+
+	m := app.selected
+	if m == nil {
+		return nil, fmt.Errorf("no metric selected")
+	}
+
+	if m.Kind == wg.KindHistogram || m.Kind == wg.KindNativeHistogram {
+		if typ != "" && typ != wg.PanelHistogram {
+			return nil, fmt.Errorf("histograms can only be shown as histograms")
+		}
+		key := m.Key()
+		return wg.NewHistogramPanel(m.Title(), key.String()), nil
+	}
+
+	if typ == wg.PanelHistogram {
+		return nil, fmt.Errorf("%s is not a histogram", m.Name)
+	}
+
+	if typ == "" {
+		typ = wg.PanelPlot
+	}
+
+	key := m.Key()
+
+	title, expr := m.Title(), key.String()
+	if app.dash.Plot.Rate {
+		title += " [rate/s]"
+		expr = fmt.Sprintf("irate(%s[%s])", expr, app.displayWindow)
+	}
+	return wg.NewQueryPanel(typ, title, expr, app.pollInterval, app.displayWindow), nil
+}
+
+// renderPanels refreshes the panels of the grid with the latest samples.
+func (app *App) renderPanels(now time.Time) {
+	for _, p := range app.dash.Grid.Panels {
+		var err error
+		switch p.Type {
+		case wg.PanelPlot:
+			err = app.updatePlotPanel(p, now)
+		case wg.PanelStat:
+			err = app.updateStatPanel(p, now)
+		case wg.PanelHistogram:
+			err = app.updateHistogramPanel(p)
+		}
+
+		if err != nil {
+			app.dash.Prompt.ShowError(fmt.Errorf("%s: %w", p.Title, err))
+			ui.Render(app.dash.Prompt)
+		}
+	}
+	ui.Render(app.dash.Grid)
+}
+
+func (app *App) updatePlotPanel(p *wg.Panel, now time.Time) error {
+	res, err := query.Eval(app.store, app.panelQueries[p], now.Add(-app.displayWindow), now, app.pollInterval)
+	if err != nil {
+		return err
+	}
+
+	lines := make([][]metric.Sample, len(res))
+	legend := make([]string, len(res))
+	for i, s := range res {
+		lines[i] = s.Samples
+		legend[i] = s.Metric.String()
+	}
+
+	if len(res) == 1 {
+		legend = nil
+	}
+
+	p.Plot.SetLines(lines, legend)
+	p.Plot.Advance(now)
+	return nil
+}
+
+func (app *App) updateStatPanel(p *wg.Panel, now time.Time) error {
+	res, err := query.Eval(app.store, app.panelQueries[p], now, now, app.pollInterval)
+	if err != nil {
+		return err
+	}
+
+	p.Stat.Values = make([]float64, len(res))
+	p.Stat.Legend = make([]string, len(res))
+	for i, s := range res {
+		p.Stat.Values[i] = s.Samples[len(s.Samples)-1].Value
+		p.Stat.Legend[i] = s.Metric.String()
+	}
+	return nil
+}
+
+// updateHistogramPanel shows the latest snapshot of the histogram matched by the
+// selector of the panel, which must match exactly one histogram.
+func (app *App) updateHistogramPanel(p *wg.Panel) error {
+	vs := app.panelSelectors[p]
+
+	var matches []metric.MetricKey
+	app.store.Histograms(func(key metric.MetricKey) {
+		if vs.Matches(key) {
+			matches = append(matches, key)
+		}
+	})
+
+	switch len(matches) {
+	case 0:
+		p.Hist = nil
+		return fmt.Errorf("no histogram matches %s", p.Metric)
+	case 1:
+	default:
+		p.Hist = nil
+		return fmt.Errorf("%s matches %d histograms", p.Metric, len(matches))
+	}
+
+	width := max(p.Rect.Dx()-2, 1)
+
+	key := matches[0]
+	if h, ok := app.store.LookupHist(key); ok {
+		p.Hist = wg.NewHistogram(h, width)
+	} else if h, ok := app.store.LookupNativeHist(key); ok {
+		p.Hist = wg.NewNativeHistogram(h, width)
+	}
+	return nil
+}
+
+func (app *App) addPanelCmd(_ string, args ...string) error {
+	var typ wg.PanelType
+	if len(args) > 0 {
+		t, err := wg.ParsePanelType(args[0])
+		if err != nil {
+			return err
+		}
+		typ = t
+	}
+	return app.addPanel(typ)
+}
+
+func (app *App) setColumns(_ string, args ...string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no number of columns specified")
+	}
+
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid number of columns \"%s\"", args[0])
+	}
+
+	if err := app.dash.Grid.SetColumns(n); err != nil {
+		return err
+	}
+
+	if app.dash.ShowGrid {
+		app.dash.Render()
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ostafen/proq/pkg/record"
+	"github.com/ostafen/proq/pkg/scrape"
+)
+
+const DefaultRecordFile = "proq.rec"
+
+// runRecord implements the record subcommand, which scrapes the targets without
+// the UI and appends their samples to a record file, until interrupted.
+func runRecord(args []string) {
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s record [targets...] [flags]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	output := fs.String("output", DefaultRecordFile, "file the samples are appended to")
+	pollInterval := fs.Duration("poll-interval", DefaultPollInterval, "the frequency the metric endpoint is queried")
+	targetsFile := fs.String("targets", "", "file listing the targets to scrape, one per line")
+	scrapeTimeout := fs.Duration("scrape-timeout", scrape.DefaultTimeout, "timeout of the scrapes of the targets which don't set their own")
+	duration := fs.Duration("duration", 0, "stop recording after the given time (0 to record until interrupted)")
+
+	specs, flags := splitArgs(args)
+	fs.Parse(flags)
+
+	targets, err := parseTargets(append(specs, fs.Args()...), *targetsFile, *scrapeTimeout)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(targets) == 0 {
+		log.Fatal("no url specified")
+	}
+
+	w, err := record.Open(*output)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := recordTargets(w, targets, *pollInterval, *duration); err != nil {
+		w.Close()
+		log.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func recordTargets(w *record.Writer, targets []scrape.Target, pollInterval, duration time.Duration) error {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+
+	var timeout <-chan time.Time
+	if duration > 0 {
+		timeout = time.After(duration)
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, res := range scrape.ScrapeAll(context.Background(), targets) {
+			if res.Err != nil {
+				log.Printf("unable to scrape %s: %s", res.Target.URL, res.Err)
+				continue
+			}
+
+			for _, err := range res.ParseErrors {
+				log.Printf("unable to parse metrics from %s: %s", res.Target.URL, err)
+			}
+
+			if err := w.Append(res.Samples(), res.Metadata); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-timeout:
+			return nil
+		case <-sig:
+			return nil
+		}
+	}
+}
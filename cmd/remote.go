@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/ostafen/proq/pkg/metric"
+	"github.com/ostafen/proq/pkg/remote"
+	"github.com/ostafen/proq/pkg/scrape"
+)
+
+// receiveWrite stores the samples of a remote-write request, as if they were scraped.
+func (app *App) receiveWrite(req *remote.WriteRequest) {
+	results := writeResults(req)
+	app.exec(func() {
+		app.ingestAll(results)
+	})
+}
+
+// writeResults splits the samples of a request into results holding at most a sample
+// for each series: the n-th result holds the n-th sample of every series, so that the
+// samples of the series of a histogram, which are sent together, are grouped again.
+func writeResults(req *remote.WriteRequest) []*scrape.Result {
+	md := req.MetadataByName()
+
+	var rounds [][]metric.RawMetric
+
+	n := 0
+	var prev string
+	for _, m := range req.Samples() {
+		key := metric.MetricKey{Name: m.Name, Labels: m.Labels}
+		if k := key.String(); k != prev {
+			n, prev = 0, k
+		}
+
+		if n == len(rounds) {
+			rounds = append(rounds, nil)
+		}
+		rounds[n] = append(rounds[n], m)
+		n++
+	}
+
+	results := make([]*scrape.Result, len(rounds))
+	for i, samples := range rounds {
+		ts := samples[0].Timestamp
+		for _, m := range samples[1:] {
+			ts = max(ts, m.Timestamp)
+		}
+		results[i] = scrape.NewResult(scrape.Target{}, samples, md, time.UnixMilli(ts))
+	}
+	return results
+}
+
+// forwardTo sends the scraped samples to the remote-write receiver at rawURL.
+func (app *App) forwardTo(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("invalid remote-write url \"%s\"", rawURL)
+	}
+
+	app.remoteWrite = remote.NewQueue(rawURL, remote.DefaultQueueConfig)
+	return nil
+}
+
+// remoteWriteStatus describes the samples forwarded so far.
+func (app *App) remoteWriteStatus() string {
+	stats := app.remoteWrite.Stats()
+
+	status := fmt.Sprintf("remote write: %d sent, %d pending, %d dropped, %d failed",
+		stats.Sent, stats.Pending, stats.Dropped, stats.Failed)
+	if stats.LastError != nil {
+		status += fmt.Sprintf(" (%s)", stats.LastError)
+	}
+	return status
+}
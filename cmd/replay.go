@@ -0,0 +1,274 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	ui "github.com/ostafen/termui/v3"
+
+	"github.com/ostafen/proq/pkg/replay"
+	"github.com/ostafen/proq/pkg/scrape"
+	"github.com/ostafen/proq/pkg/store"
+)
+
+const (
+	// replayTickInterval is how often the clock of the player is advanced.
+	replayTickInterval = 200 * time.Millisecond
+
+	maxReplaySpeed = 64
+	minReplaySpeed = 1.0 / 8
+)
+
+// runReplay implements the replay subcommand, which drives the dashboard
+// from snapshots captured in the past, rather than by scraping targets.
+func runReplay(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s replay <dir-or-file> [flags]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	displayWindow := fs.Duration("window", DefaultDisplayWindow, "time size of displayed window")
+	pollInterval := fs.Duration("poll-interval", 0, "the interval between snapshots (guessed from the snapshots by default)")
+	speed := fs.Float64("speed", 1, "how many times faster than real time snapshots are played")
+	dashboardFile := fs.String("dashboard", "", "YAML file declaring the panels of the dashboard")
+	exportFile := fs.String("export", "", "export the replayed series to the given .csv or .jsonl file and exit")
+	selector := fs.String("select", DefaultExportSelector, "the series exported by --export")
+	listenAddr := fs.String("listen", "", "address to serve the Prometheus HTTP API on, e.g. \":9091\"")
+
+	paths, flags := splitArgs(args)
+	fs.Parse(flags)
+
+	paths = append(paths, fs.Args()...)
+	if len(paths) != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	snapshots, err := replay.Load(paths[0])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if *exportFile != "" {
+		st := store.NewMetricStore(len(snapshots))
+		for _, s := range snapshots {
+			storeResult(st, s.Result)
+		}
+
+		if err := exportSeries(st, *selector, *exportFile); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	interval := *pollInterval
+	if interval <= 0 {
+		interval = snapshotInterval(snapshots)
+	}
+
+	app := newApp(nil, interval, *displayWindow)
+
+	app.player = replay.NewPlayer(snapshots)
+	if err := app.player.SetSpeed(*speed); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if *dashboardFile != "" {
+		if err := app.openDashboard(*dashboardFile); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	if *listenAddr != "" {
+		if err := app.serve(*listenAddr, serverOptions{}); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	app.Start()
+}
+
+// snapshotInterval returns the median interval between snapshots, which is
+// robust to the occasional missing or repeated snapshot.
+func snapshotInterval(snapshots []replay.Snapshot) time.Duration {
+	intervals := make([]time.Duration, 0, len(snapshots))
+	for i := 1; i < len(snapshots); i++ {
+		if d := snapshots[i].Timestamp.Sub(snapshots[i-1].Timestamp); d > 0 {
+			intervals = append(intervals, d)
+		}
+	}
+
+	if len(intervals) == 0 {
+		return DefaultPollInterval
+	}
+
+	slices.Sort(intervals)
+	return intervals[len(intervals)/2]
+}
+
+// replay loads the snapshots which became due since the last tick.
+func (app *App) replay(elapsed time.Duration) {
+	due := app.player.Advance(elapsed)
+
+	results := make([]*scrape.Result, len(due))
+	for i, s := range due {
+		results[i] = s.Result
+	}
+	app.ingestAll(results)
+	app.showReplayStatus()
+}
+
+// onReplayKeyPressed handles the keybindings controlling the player.
+func (app *App) onReplayKeyPressed(key string) bool {
+	p := app.player
+
+	switch key {
+	case "<C-p>":
+		p.TogglePause()
+	case "<C-f>":
+		p.SetSpeed(min(p.Speed()*2, maxReplaySpeed))
+	case "<C-b>":
+		p.SetSpeed(max(p.Speed()/2, minReplaySpeed))
+	case "<C-n>":
+		if !p.Paused() {
+			p.TogglePause()
+		}
+		app.step()
+	case "<PageUp>":
+		app.seek(p.Now().Add(-app.displayWindow))
+	case "<PageDown>":
+		app.seek(p.Now().Add(app.displayWindow))
+	case "<Home>":
+		app.seek(p.Start())
+	case "<End>":
+		app.seek(p.End())
+	default:
+		return false
+	}
+
+	app.showReplayStatus()
+	return true
+}
+
+// step loads the next snapshot.
+func (app *App) step() {
+	for _, s := range app.player.Step() {
+		app.ingest(s.Result)
+	}
+	app.refresh()
+}
+
+// seek moves the player to t, replacing the content of the store with the snapshots
+// of the display window ending at t.
+func (app *App) seek(t time.Time) {
+	app.unbind()
+	app.store.Reset()
+
+	for _, s := range app.player.Seek(t, app.displayWindow) {
+		app.ingest(s.Result)
+	}
+
+	app.dash.Plot.Reset()
+	for _, p := range app.dash.Grid.Panels {
+		if p.Plot != nil {
+			p.Plot.Reset()
+		}
+	}
+
+	if app.query == nil {
+		app.renderSelected()
+	}
+	app.refresh()
+}
+
+func (app *App) showReplayStatus() {
+	p := app.player
+
+	state := "playing"
+	if p.Paused() {
+		state = "paused"
+	}
+
+	app.dash.Prompt.Title = fmt.Sprintf("Replay [%s x%s] %s / %s",
+		state,
+		strconv.FormatFloat(p.Speed(), 'g', -1, 64),
+		p.Now().Format(time.DateTime),
+		p.End().Format(time.DateTime),
+	)
+	ui.Render(app.dash.Prompt)
+}
+
+// seekCmd moves the player by a duration, when prefixed by a sign,
+// or to a time of the day or a date, e.g. "-5m", "10:30:00" or "2024-03-01 10:30:00".
+func (app *App) seekCmd(_ string, args ...string) error {
+	if app.player == nil {
+		return fmt.Errorf("not replaying")
+	}
+
+	if len(args) == 0 {
+		return fmt.Errorf("usage: :seek <+duration|-duration|time>")
+	}
+
+	t, err := parseSeekTime(strings.Join(args, " "), app.player.Now())
+	if err != nil {
+		return err
+	}
+
+	app.seek(t)
+	app.showReplayStatus()
+	return nil
+}
+
+func parseSeekTime(s string, now time.Time) (time.Time, error) {
+	if strings.HasPrefix(s, "+") || strings.HasPrefix(s, "-") {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid duration \"%s\"", s)
+		}
+		return now.Add(d), nil
+	}
+
+	if t, err := time.ParseInLocation(time.TimeOnly, s, now.Location()); err == nil {
+		y, m, d := now.Date()
+		return time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), 0, now.Location()), nil
+	}
+
+	for _, layout := range []string{time.DateTime, time.RFC3339} {
+		if t, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time \"%s\"", s)
+}
+
+func (app *App) setSpeed(_ string, args ...string) error {
+	if app.player == nil {
+		return fmt.Errorf("not replaying")
+	}
+
+	if len(args) != 1 {
+		return fmt.Errorf("usage: :speed <factor>")
+	}
+
+	speed, err := strconv.ParseFloat(strings.TrimPrefix(args[0], "x"), 64)
+	if err != nil {
+		return fmt.Errorf("invalid speed \"%s\"", args[0])
+	}
+
+	if err := app.player.SetSpeed(speed); err != nil {
+		return err
+	}
+	app.showReplayStatus()
+	return nil
+}
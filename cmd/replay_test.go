@@ -0,0 +1,63 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	ui "github.com/ostafen/termui/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ostafen/proq/pkg/metric"
+	"github.com/ostafen/proq/pkg/replay"
+	"github.com/ostafen/proq/pkg/scrape"
+	wg "github.com/ostafen/proq/pkg/widgets"
+)
+
+func TestSeekMissingSelected(t *testing.T) {
+	if err := ui.Init(); err != nil {
+		t.Skipf("no terminal available: %s", err)
+	}
+	defer ui.Close()
+
+	start := time.Unix(1000, 0)
+
+	// the histogram and the summary only appear in the last snapshot.
+	h := metric.Histogram{
+		Name:  "latency_seconds",
+		Bins:  []metric.Bin{{Value: 1, Count: 1}, {Value: math.Inf(1), Count: 2}},
+		Sum:   3,
+		Count: 2,
+	}
+
+	metrics := append(h.Series(),
+		metric.RawMetric{Name: "rpc_seconds", Labels: []metric.Label{{Name: "quantile", Value: "0.5"}}, Value: 0.2},
+		metric.RawMetric{Name: "rpc_seconds_sum", Value: 1},
+		metric.RawMetric{Name: "rpc_seconds_count", Value: 5},
+	)
+	metadata := map[string]metric.Metadata{
+		"latency_seconds": {Name: "latency_seconds", Type: metric.TypeHistogram},
+		"rpc_seconds":     {Name: "rpc_seconds", Type: metric.TypeSummary},
+	}
+
+	end := start.Add(time.Minute)
+	snapshots := []replay.Snapshot{
+		{Timestamp: start, Result: scrape.NewResult(scrape.Target{}, []metric.RawMetric{{Name: "up", Value: 1}}, nil, start)},
+		{Timestamp: end, Result: scrape.NewResult(scrape.Target{}, metrics, metadata, end)},
+	}
+
+	app := newApp(nil, 10*time.Second, 30*time.Second)
+	app.player = replay.NewPlayer(snapshots)
+	app.seek(end)
+
+	for _, m := range []wg.MetricInfo{
+		{Name: "latency_seconds", Kind: wg.KindHistogram},
+		{Name: "rpc_seconds", Kind: wg.KindSummary},
+	} {
+		app.renderMetric(m)
+
+		require.NotPanics(t, func() { app.seek(start) })
+		require.NotPanics(t, func() { app.renderMetric(m) })
+		require.NotPanics(t, app.refresh)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	ui "github.com/ostafen/termui/v3"
+
+	"github.com/ostafen/proq/pkg/scrape"
+)
+
+// setTargetStatus records the outcome of the last scrape of a target.
+func (app *App) setTargetStatus(res *scrape.Result) {
+	for i, t := range app.targets {
+		if t.URL != res.Target.URL || t.Job != res.Target.Job {
+			continue
+		}
+
+		status := &app.targetStatus[i]
+		status.LastScrape = res.Timestamp
+		status.Duration = res.Duration
+		status.Err = res.Err
+		status.ParseErrors = len(res.ParseErrors)
+		status.ParseError = nil
+
+		if len(res.ParseErrors) > 0 {
+			status.ParseError = res.ParseErrors[0]
+		}
+		return
+	}
+}
+
+// showStatus renders the status bar, showing the last scrape of each target
+// and the samples forwarded by remote write.
+func (app *App) showStatus() {
+	sb := app.dash.Status
+
+	sb.SetTargets(app.targetStatus)
+	if app.remoteWrite != nil {
+		sb.SetExtra(app.remoteWriteStatus())
+	}
+	ui.Render(sb)
+}
@@ -3,8 +3,12 @@ module github.com/ostafen/proq
 go 1.23.3
 
 require (
+	github.com/golang/snappy v0.0.4
 	github.com/ostafen/termui/v3 v3.0.0-20250309112533-da79a6924479
+	github.com/prometheus/client_model v0.6.2
 	github.com/stretchr/testify v1.10.0
+	google.golang.org/protobuf v1.36.6
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -13,5 +17,4 @@ require (
 	github.com/mitchellh/go-wordwrap v0.0.0-20150314170334-ad45545899c7 // indirect
 	github.com/nsf/termbox-go v0.0.0-20190121233118-02980233997d // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
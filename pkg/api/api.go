@@ -0,0 +1,394 @@
+// Package api implements a subset of the Prometheus HTTP API, so that tools such
+// as Grafana can query the series held by proq.
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ostafen/proq/pkg/metric"
+	"github.com/ostafen/proq/pkg/query"
+)
+
+const metricNameLabel = "__name__"
+
+// maxPoints bounds the number of steps of a range query, as Prometheus does.
+const maxPoints = 11000
+
+// Executor runs fn with exclusive access to the storage, and waits for it to complete.
+// It lets the API share a storage which is not safe for concurrent use.
+type Executor func(fn func())
+
+// Handler serves the API over a storage.
+type Handler struct {
+	st   query.Storage
+	exec Executor
+
+	// now returns the time instant queries are evaluated at by default.
+	// It is called by the executor.
+	now func() time.Time
+
+	mux *http.ServeMux
+}
+
+func NewHandler(st query.Storage, exec Executor, now func() time.Time) *Handler {
+	h := &Handler{
+		st:   st,
+		exec: exec,
+		now:  now,
+		mux:  http.NewServeMux(),
+	}
+
+	h.mux.HandleFunc("/api/v1/query", h.query)
+	h.mux.HandleFunc("/api/v1/query_range", h.queryRange)
+	h.mux.HandleFunc("/api/v1/series", h.series)
+	h.mux.HandleFunc("/api/v1/labels", h.labels)
+	h.mux.HandleFunc("/api/v1/label/{name}/values", h.labelValues)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+type response struct {
+	Status    string `json:"status"`
+	Data      any    `json:"data,omitempty"`
+	ErrorType string `json:"errorType,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type apiError struct {
+	typ    string
+	status int
+	err    error
+}
+
+func badData(err error) *apiError {
+	return &apiError{typ: "bad_data", status: http.StatusBadRequest, err: err}
+}
+
+func execution(err error) *apiError {
+	return &apiError{typ: "execution", status: http.StatusUnprocessableEntity, err: err}
+}
+
+func respond(w http.ResponseWriter, data any, apiErr *apiError) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if apiErr != nil {
+		w.WriteHeader(apiErr.status)
+		json.NewEncoder(w).Encode(response{
+			Status:    "error",
+			ErrorType: apiErr.typ,
+			Error:     apiErr.err.Error(),
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(response{
+		Status: "success",
+		Data:   data,
+	})
+}
+
+type queryResult struct {
+	ResultType string `json:"resultType"`
+	Result     any    `json:"result"`
+}
+
+type vectorSample struct {
+	Metric labelSet `json:"metric"`
+	Value  point    `json:"value"`
+}
+
+type matrixSeries struct {
+	Metric labelSet `json:"metric"`
+	Values []point  `json:"values"`
+}
+
+// labelSet holds the labels of a series, including its name.
+type labelSet map[string]string
+
+func newLabelSet(mk metric.MetricKey) labelSet {
+	ls := make(labelSet, len(mk.Labels)+1)
+	if mk.Name != "" {
+		ls[metricNameLabel] = mk.Name
+	}
+
+	for _, l := range mk.Labels {
+		ls[l.Name] = l.Value
+	}
+	return ls
+}
+
+// point is encoded as a [<unix seconds>, "<value>"] pair.
+type point metric.Sample
+
+func (p point) MarshalJSON() ([]byte, error) {
+	ts := float64(p.Timestamp) / 1000
+	return json.Marshal([]any{ts, metric.FormatFloat(p.Value)})
+}
+
+func (h *Handler) query(w http.ResponseWriter, r *http.Request) {
+	e, err := query.Parse(r.FormValue("query"))
+	if err != nil {
+		respond(w, nil, badData(err))
+		return
+	}
+
+	var (
+		res    queryResult
+		apiErr *apiError
+	)
+
+	h.exec(func() {
+		ts := h.now()
+		if v := r.FormValue("time"); v != "" {
+			if ts, err = parseTime(v); err != nil {
+				apiErr = badData(fmt.Errorf("invalid time: %w", err))
+				return
+			}
+		}
+
+		if ms, ok := e.(*query.MatrixSelector); ok {
+			res = h.rangeSelector(ms, ts)
+			return
+		}
+
+		series, err := query.Eval(h.st, e, ts, ts, time.Second)
+		if err != nil {
+			apiErr = execution(err)
+			return
+		}
+
+		if query.IsScalar(e) {
+			res = queryResult{ResultType: "scalar", Result: point(series[0].Samples[0])}
+			return
+		}
+
+		vec := make([]vectorSample, len(series))
+		for i, s := range series {
+			vec[i] = vectorSample{Metric: newLabelSet(s.Metric), Value: point(s.Samples[0])}
+		}
+		res = queryResult{ResultType: "vector", Result: vec}
+	})
+
+	respond(w, res, apiErr)
+}
+
+// rangeSelector returns the raw samples selected by a range selector, such as "up[5m]".
+func (h *Handler) rangeSelector(ms *query.MatrixSelector, ts time.Time) queryResult {
+	start, end := ts.Add(-ms.Range).UnixMilli(), ts.UnixMilli()
+
+	matrix := []matrixSeries{}
+	for _, s := range query.Select(h.st, ms.Vector) {
+		var values []point
+		for _, sample := range s.Samples {
+			if sample.Timestamp > start && sample.Timestamp <= end {
+				values = append(values, point(sample))
+			}
+		}
+
+		if len(values) > 0 {
+			matrix = append(matrix, matrixSeries{Metric: newLabelSet(s.Metric), Values: values})
+		}
+	}
+	return queryResult{ResultType: "matrix", Result: matrix}
+}
+
+func (h *Handler) queryRange(w http.ResponseWriter, r *http.Request) {
+	e, err := query.Parse(r.FormValue("query"))
+	if err != nil {
+		respond(w, nil, badData(err))
+		return
+	}
+
+	start, err := parseTime(r.FormValue("start"))
+	if err != nil {
+		respond(w, nil, badData(fmt.Errorf("invalid start: %w", err)))
+		return
+	}
+
+	end, err := parseTime(r.FormValue("end"))
+	if err != nil {
+		respond(w, nil, badData(fmt.Errorf("invalid end: %w", err)))
+		return
+	}
+
+	// samples have a millisecond resolution, which is also the smallest step.
+	step, err := parseDuration(r.FormValue("step"))
+	if err != nil || step < time.Millisecond {
+		respond(w, nil, badData(fmt.Errorf("invalid step \"%s\"", r.FormValue("step"))))
+		return
+	}
+
+	if end.Before(start) {
+		respond(w, nil, badData(errors.New("end is before start")))
+		return
+	}
+
+	if end.Sub(start)/step > maxPoints {
+		respond(w, nil, badData(fmt.Errorf("exceeded maximum resolution of %d points per series", maxPoints)))
+		return
+	}
+
+	var series []query.Series
+	h.exec(func() {
+		series, err = query.Eval(h.st, e, start, end, step)
+	})
+
+	if err != nil {
+		respond(w, nil, execution(err))
+		return
+	}
+
+	matrix := make([]matrixSeries, len(series))
+	for i, s := range series {
+		values := make([]point, len(s.Samples))
+		for j, sample := range s.Samples {
+			values[j] = point(sample)
+		}
+		matrix[i] = matrixSeries{Metric: newLabelSet(s.Metric), Values: values}
+	}
+	respond(w, queryResult{ResultType: "matrix", Result: matrix}, nil)
+}
+
+func (h *Handler) series(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	if len(r.Form["match[]"]) == 0 {
+		respond(w, nil, badData(errors.New("no match[] parameter provided")))
+		return
+	}
+
+	keys, err := h.matchingSeries(r)
+	if err != nil {
+		respond(w, nil, badData(err))
+		return
+	}
+
+	sets := make([]labelSet, len(keys))
+	for i, k := range keys {
+		sets[i] = newLabelSet(k)
+	}
+	respond(w, sets, nil)
+}
+
+func (h *Handler) labels(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.matchingSeries(r)
+	if err != nil {
+		respond(w, nil, badData(err))
+		return
+	}
+
+	names := []string{}
+	for _, k := range keys {
+		for name := range newLabelSet(k) {
+			names = append(names, name)
+		}
+	}
+
+	slices.Sort(names)
+	respond(w, slices.Compact(names), nil)
+}
+
+func (h *Handler) labelValues(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	keys, err := h.matchingSeries(r)
+	if err != nil {
+		respond(w, nil, badData(err))
+		return
+	}
+
+	values := []string{}
+	for _, k := range keys {
+		if v, ok := newLabelSet(k)[name]; ok {
+			values = append(values, v)
+		}
+	}
+
+	slices.Sort(values)
+	respond(w, slices.Compact(values), nil)
+}
+
+// matchingSeries returns the keys of the series matching any of the match[] selectors,
+// or of all the series when none is given. When a time range is given, only the series
+// with samples in the range are returned.
+func (h *Handler) matchingSeries(r *http.Request) ([]metric.MetricKey, error) {
+	r.ParseForm()
+
+	var selectors []*query.VectorSelector
+	for _, m := range r.Form["match[]"] {
+		vs, err := query.ParseSelector(m)
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, vs)
+	}
+
+	start, end := int64(math.MinInt64), int64(math.MaxInt64)
+	if v := r.FormValue("start"); v != "" {
+		t, err := parseTime(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start: %w", err)
+		}
+		start = t.UnixMilli()
+	}
+
+	if v := r.FormValue("end"); v != "" {
+		t, err := parseTime(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end: %w", err)
+		}
+		end = t.UnixMilli()
+	}
+
+	var keys []metric.MetricKey
+	h.exec(func() {
+		h.st.Series(func(key metric.MetricKey) {
+			matched := len(selectors) == 0 || slices.ContainsFunc(selectors, func(vs *query.VectorSelector) bool {
+				return vs.Matches(key)
+			})
+
+			if matched && h.hasSamples(key, start, end) {
+				keys = append(keys, key)
+			}
+		})
+	})
+
+	slices.SortFunc(keys, func(a, b metric.MetricKey) int {
+		return strings.Compare(a.String(), b.String())
+	})
+	return keys, nil
+}
+
+func (h *Handler) hasSamples(key metric.MetricKey, start, end int64) bool {
+	found := false
+	h.st.Samples(key, func(s metric.Sample) {
+		found = found || (s.Timestamp >= start && s.Timestamp <= end)
+	})
+	return found
+}
+
+// parseTime parses a unix timestamp in seconds, possibly fractional, or an RFC 3339 time.
+func parseTime(s string) (time.Time, error) {
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.UnixMilli(int64(math.Round(secs * 1000))), nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// parseDuration parses a duration in seconds, possibly fractional, or in the "5m" form.
+func parseDuration(s string) (time.Duration, error) {
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(secs * float64(time.Second)), nil
+	}
+	return time.ParseDuration(s)
+}
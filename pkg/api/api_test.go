@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ostafen/proq/pkg/metric"
+	"github.com/ostafen/proq/pkg/store"
+)
+
+func testHandler() *Handler {
+	st := store.NewMetricStore(10)
+	for i := range 5 {
+		ts := int64(i+1) * 1000
+		st.Update(&metric.RawMetric{Name: "requests_total", Labels: []metric.Label{{Name: "code", Value: "200"}}, Value: float64(i * 10), Timestamp: ts})
+		st.Update(&metric.RawMetric{Name: "requests_total", Labels: []metric.Label{{Name: "code", Value: "500"}}, Value: float64(i), Timestamp: ts})
+	}
+	st.Update(&metric.RawMetric{Name: "up", Value: 1, Timestamp: 5000})
+
+	exec := func(fn func()) { fn() }
+	return NewHandler(st, exec, func() time.Time { return time.UnixMilli(5000) })
+}
+
+func get(t *testing.T, h http.Handler, path string, params url.Values) (int, map[string]any) {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path+"?"+params.Encode(), nil))
+
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	return rec.Code, body
+}
+
+func TestQuery(t *testing.T) {
+	h := testHandler()
+
+	code, body := get(t, h, "/api/v1/query", url.Values{"query": {`requests_total{code="500"}`}})
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, map[string]any{
+		"status": "success",
+		"data": map[string]any{
+			"resultType": "vector",
+			"result": []any{
+				map[string]any{
+					"metric": map[string]any{"__name__": "requests_total", "code": "500"},
+					"value":  []any{5.0, "4"},
+				},
+			},
+		},
+	}, body)
+
+	_, body = get(t, h, "/api/v1/query", url.Values{"query": {"1+1"}, "time": {"2"}})
+	require.Equal(t, map[string]any{"resultType": "scalar", "result": []any{2.0, "2"}}, body["data"])
+
+	_, body = get(t, h, "/api/v1/query", url.Values{"query": {"up[10s]"}})
+	require.Equal(t, "matrix", body["data"].(map[string]any)["resultType"])
+
+	code, body = get(t, h, "/api/v1/query", url.Values{"query": {"sum("}})
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Equal(t, "bad_data", body["errorType"])
+}
+
+func TestQueryRange(t *testing.T) {
+	h := testHandler()
+
+	params := url.Values{
+		"query": {`sum(requests_total)`},
+		"start": {"2"},
+		"end":   {"4"},
+		"step":  {"1s"},
+	}
+
+	code, body := get(t, h, "/api/v1/query_range", params)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, map[string]any{
+		"resultType": "matrix",
+		"result": []any{
+			map[string]any{
+				"metric": map[string]any{},
+				"values": []any{[]any{2.0, "11"}, []any{3.0, "22"}, []any{4.0, "33"}},
+			},
+		},
+	}, body["data"])
+
+	for _, step := range []string{"0", "0.0001"} {
+		params.Set("step", step)
+		code, body = get(t, h, "/api/v1/query_range", params)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, "bad_data", body["errorType"])
+	}
+}
+
+func TestSeriesAndLabels(t *testing.T) {
+	h := testHandler()
+
+	_, body := get(t, h, "/api/v1/series", url.Values{"match[]": {`{code="200"}`, "up"}})
+	require.Equal(t, []any{
+		map[string]any{"__name__": "requests_total", "code": "200"},
+		map[string]any{"__name__": "up"},
+	}, body["data"])
+
+	code, _ := get(t, h, "/api/v1/series", nil)
+	require.Equal(t, http.StatusBadRequest, code)
+
+	_, body = get(t, h, "/api/v1/labels", nil)
+	require.Equal(t, []any{"__name__", "code"}, body["data"])
+
+	_, body = get(t, h, "/api/v1/label/code/values", nil)
+	require.Equal(t, []any{"200", "500"}, body["data"])
+
+	_, body = get(t, h, "/api/v1/label/__name__/values", url.Values{"start": {"4.5"}})
+	require.Equal(t, []any{"requests_total", "up"}, body["data"])
+}
@@ -0,0 +1,126 @@
+package dashboard
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	PanelPlot      = "plot"
+	PanelHistogram = "histogram"
+	PanelStat      = "stat"
+)
+
+// Dashboard declares the panels of the grid, along with its number of columns.
+type Dashboard struct {
+	Columns int     `yaml:"columns,omitempty"`
+	Panels  []Panel `yaml:"panels"`
+}
+
+// Panel declares what a panel shows and how. Plot and stat panels either show a query or
+// a metric selector, such as http_requests_total{status="500"}, while histogram panels
+// show the histogram identified by the selector.
+type Panel struct {
+	Title      string      `yaml:"title,omitempty"`
+	Type       string      `yaml:"type,omitempty"`
+	Metric     string      `yaml:"metric,omitempty"`
+	Query      string      `yaml:"query,omitempty"`
+	Unit       string      `yaml:"unit,omitempty"`
+	Thresholds []Threshold `yaml:"thresholds,omitempty"`
+	Position   *Position   `yaml:"position,omitempty"`
+}
+
+// Threshold colors the values of a panel which are greater than or equal to Value.
+type Threshold struct {
+	Value float64 `yaml:"value"`
+	Color string  `yaml:"color"`
+}
+
+// Position places a panel in the grid. Width and height are the number of
+// columns and rows it spans, which default to one.
+type Position struct {
+	Row    int `yaml:"row"`
+	Col    int `yaml:"col"`
+	Width  int `yaml:"width,omitempty"`
+	Height int `yaml:"height,omitempty"`
+}
+
+// Load reads a dashboard from a YAML file, filling in defaults.
+func Load(path string) (*Dashboard, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var d Dashboard
+	if err := yaml.Unmarshal(data, &d); err != nil {
+		return nil, fmt.Errorf("invalid dashboard \"%s\": %w", path, err)
+	}
+
+	if err := d.validate(); err != nil {
+		return nil, fmt.Errorf("invalid dashboard \"%s\": %w", path, err)
+	}
+	return &d, nil
+}
+
+// Save writes the dashboard to a YAML file.
+func Save(path string, d *Dashboard) error {
+	data, err := yaml.Marshal(d)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func (d *Dashboard) validate() error {
+	if d.Columns < 0 {
+		return fmt.Errorf("invalid number of columns: %d", d.Columns)
+	}
+
+	for i := range d.Panels {
+		if err := d.Panels[i].validate(); err != nil {
+			return fmt.Errorf("panel %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func (p *Panel) validate() error {
+	if p.Type == "" {
+		p.Type = PanelPlot
+	}
+
+	switch p.Type {
+	case PanelPlot, PanelStat:
+		if (p.Metric == "") == (p.Query == "") {
+			return fmt.Errorf("exactly one of metric and query must be set")
+		}
+	case PanelHistogram:
+		if p.Metric == "" {
+			return fmt.Errorf("histogram panels require a metric")
+		}
+
+		if p.Query != "" {
+			return fmt.Errorf("histogram panels can't show a query")
+		}
+	default:
+		return fmt.Errorf("unknown panel type \"%s\"", p.Type)
+	}
+
+	if pos := p.Position; pos != nil {
+		if pos.Width == 0 {
+			pos.Width = 1
+		}
+
+		if pos.Height == 0 {
+			pos.Height = 1
+		}
+
+		if pos.Row < 0 || pos.Col < 0 || pos.Width < 0 || pos.Height < 0 {
+			return fmt.Errorf("invalid position")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package dashboard
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dashboard.yaml")
+
+	err := os.WriteFile(path, []byte(`
+columns: 3
+panels:
+  - title: Error rate
+    query: sum(rate(http_requests_total{status="500"}[1m]))
+    thresholds:
+      - value: 1
+        color: red
+  - type: stat
+    metric: queue_depth{job="worker"}
+    unit: jobs
+    position: {row: 1, col: 0, width: 2}
+  - type: histogram
+    metric: request_duration_seconds
+`), 0o644)
+	require.NoError(t, err)
+
+	d, err := Load(path)
+	require.NoError(t, err)
+
+	require.Equal(t, &Dashboard{
+		Columns: 3,
+		Panels: []Panel{
+			{
+				Title:      "Error rate",
+				Type:       PanelPlot,
+				Query:      `sum(rate(http_requests_total{status="500"}[1m]))`,
+				Thresholds: []Threshold{{Value: 1, Color: "red"}},
+			},
+			{
+				Type:     PanelStat,
+				Metric:   `queue_depth{job="worker"}`,
+				Unit:     "jobs",
+				Position: &Position{Row: 1, Col: 0, Width: 2, Height: 1},
+			},
+			{
+				Type:   PanelHistogram,
+				Metric: "request_duration_seconds",
+			},
+		},
+	}, d)
+
+	saved := filepath.Join(t.TempDir(), "saved.yaml")
+	require.NoError(t, Save(saved, d))
+
+	reloaded, err := Load(saved)
+	require.NoError(t, err)
+	require.Equal(t, d, reloaded)
+}
+
+func TestLoadInvalid(t *testing.T) {
+	cases := []string{
+		`panels: [{type: gauge, query: up}]`,
+		`panels: [{type: plot}]`,
+		`panels: [{type: plot, query: up, metric: up}]`,
+		`panels: [{type: histogram, query: up}]`,
+		`columns: -1`,
+		`panels: [{query: up, position: {row: -1, col: 0}}]`,
+	}
+
+	for _, c := range cases {
+		path := filepath.Join(t.TempDir(), "dashboard.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(c), 0o644))
+
+		_, err := Load(path)
+		require.Error(t, err, c)
+	}
+}
@@ -0,0 +1,205 @@
+// Package export writes stored series to CSV or JSON lines files, with a row for each sample.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ostafen/proq/pkg/metric"
+	"github.com/ostafen/proq/pkg/query"
+)
+
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatJSON Format = "json"
+)
+
+// FormatOf guesses the format of a file from its extension.
+func FormatOf(path string) (Format, error) {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".csv":
+		return FormatCSV, nil
+	case ".json", ".jsonl", ".ndjson":
+		return FormatJSON, nil
+	default:
+		return "", fmt.Errorf("unsupported export format \"%s\": use .csv or .jsonl", ext)
+	}
+}
+
+// ErrNoMatch is returned by File when no series matches the selector.
+var ErrNoMatch = errors.New("no series matches the selector")
+
+// Storage is the source of the exported series.
+type Storage interface {
+	query.Storage
+	LookupHist(mk metric.MetricKey) (*metric.Histogram, bool)
+}
+
+// File exports the series matching the selector to a file, whose format depends on its extension.
+// It returns the number of exported series. The file is left untouched when no series matches.
+func File(path string, st Storage, vs *query.VectorSelector) (int, error) {
+	format, err := FormatOf(path)
+	if err != nil {
+		return 0, err
+	}
+
+	series := selectSeries(st, vs)
+	if len(series) == 0 {
+		return 0, ErrNoMatch
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := writeSeries(f, format, series); err != nil {
+		f.Close()
+		return 0, err
+	}
+	return len(series), f.Close()
+}
+
+// Write exports the samples of the series matching the selector. Histograms are exported
+// through their _bucket, _sum and _count series, which are also selected when the selector
+// matches the histogram itself, e.g. "http_request_duration_seconds". It returns the number
+// of exported series.
+func Write(w io.Writer, format Format, st Storage, vs *query.VectorSelector) (int, error) {
+	series := selectSeries(st, vs)
+	return len(series), writeSeries(w, format, series)
+}
+
+func selectSeries(st Storage, vs *query.VectorSelector) []query.Series {
+	return query.Select(st, &histogramSelector{VectorSelector: vs, st: st})
+}
+
+func writeSeries(w io.Writer, format Format, series []query.Series) error {
+	switch format {
+	case FormatCSV:
+		return writeCSV(w, series)
+	case FormatJSON:
+		return writeJSON(w, series)
+	}
+	return fmt.Errorf("unsupported export format \"%s\"", format)
+}
+
+// histogramSelector matches the series of the histograms matching the selector,
+// besides the series matching it.
+type histogramSelector struct {
+	*query.VectorSelector
+	st Storage
+}
+
+func (hs *histogramSelector) Matches(key metric.MetricKey) bool {
+	if hs.VectorSelector.Matches(key) {
+		return true
+	}
+
+	hk, ok := histogramKey(key)
+	if !ok {
+		return false
+	}
+
+	if _, ok := hs.st.LookupHist(hk); !ok {
+		return false
+	}
+	return hs.VectorSelector.Matches(hk)
+}
+
+// histogramKey returns the key of the histogram a _bucket, _sum or _count series may belong to.
+func histogramKey(key metric.MetricKey) (metric.MetricKey, bool) {
+	for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+		if !strings.HasSuffix(key.Name, suffix) {
+			continue
+		}
+
+		hk := metric.MetricKey{Name: strings.TrimSuffix(key.Name, suffix)}
+		for _, l := range key.Labels {
+			if l.Name != "le" {
+				hk.Labels = append(hk.Labels, l)
+			}
+		}
+		return hk, true
+	}
+	return metric.MetricKey{}, false
+}
+
+func writeCSV(w io.Writer, series []query.Series) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"timestamp", "name", "labels", "value"}); err != nil {
+		return err
+	}
+
+	for _, s := range series {
+		labels := make([]string, len(s.Metric.Labels))
+		for i, l := range s.Metric.Labels {
+			labels[i] = l.String()
+		}
+
+		for _, sample := range s.Samples {
+			err := cw.Write([]string{
+				strconv.FormatInt(sample.Timestamp, 10),
+				s.Metric.Name,
+				strings.Join(labels, ","),
+				metric.FormatFloat(sample.Value),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+type jsonSample struct {
+	Timestamp int64             `json:"timestamp"`
+	Name      string            `json:"name"`
+	Labels    map[string]string `json:"labels"`
+	Value     jsonFloat         `json:"value"`
+}
+
+// jsonFloat encodes special values, which JSON numbers can't represent, as strings.
+type jsonFloat float64
+
+func (f jsonFloat) MarshalJSON() ([]byte, error) {
+	v := float64(f)
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return json.Marshal(metric.FormatFloat(v))
+	}
+	return json.Marshal(v)
+}
+
+func writeJSON(w io.Writer, series []query.Series) error {
+	enc := json.NewEncoder(w)
+	for _, s := range series {
+		labels := make(map[string]string, len(s.Metric.Labels))
+		for _, l := range s.Metric.Labels {
+			labels[l.Name] = l.Value
+		}
+
+		for _, sample := range s.Samples {
+			err := enc.Encode(jsonSample{
+				Timestamp: sample.Timestamp,
+				Name:      s.Metric.Name,
+				Labels:    labels,
+				Value:     jsonFloat(sample.Value),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package export
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ostafen/proq/pkg/metric"
+	"github.com/ostafen/proq/pkg/query"
+	"github.com/ostafen/proq/pkg/store"
+)
+
+func testStore() *store.MetricStore {
+	st := store.NewMetricStore(10)
+
+	for i, ts := range []int64{1000, 2000} {
+		st.Update(&metric.RawMetric{
+			Name:      "requests_total",
+			Labels:    []metric.Label{{Name: "code", Value: "200"}, {Name: "path", Value: "/"}},
+			Value:     float64(i + 1),
+			Timestamp: ts,
+		})
+	}
+	st.Update(&metric.RawMetric{Name: "temperature", Value: math.NaN(), Timestamp: 1000})
+
+	st.UpdateHistograms(map[string]metric.Histogram{
+		"latency_seconds": {
+			Name:      "latency_seconds",
+			Bins:      []metric.Bin{{Value: 0.5, Count: 1}, {Value: math.Inf(1), Count: 2}},
+			Sum:       1.5,
+			Count:     2,
+			Timestamp: 1000,
+		},
+	})
+	return st
+}
+
+func TestWriteCSV(t *testing.T) {
+	vs, err := query.ParseSelector(`requests_total{code="200"}`)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	n, err := Write(&buf, FormatCSV, testStore(), vs)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	require.Equal(t, `timestamp,name,labels,value
+1000,requests_total,"code=""200"",path=""/""",1
+2000,requests_total,"code=""200"",path=""/""",2
+`, buf.String())
+}
+
+func TestWriteJSON(t *testing.T) {
+	vs, err := query.ParseSelector(`temperature`)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	_, err = Write(&buf, FormatJSON, testStore(), vs)
+	require.NoError(t, err)
+	require.Equal(t, `{"timestamp":1000,"name":"temperature","labels":{},"value":"NaN"}`+"\n", buf.String())
+}
+
+func TestWriteHistogram(t *testing.T) {
+	vs, err := query.ParseSelector(`latency_seconds`)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	n, err := Write(&buf, FormatCSV, testStore(), vs)
+	require.NoError(t, err)
+	require.Equal(t, 4, n)
+
+	require.Equal(t, `timestamp,name,labels,value
+1000,latency_seconds_bucket,"le=""+Inf""",2
+1000,latency_seconds_bucket,"le=""0.5""",1
+1000,latency_seconds_count,,2
+1000,latency_seconds_sum,,1.5
+`, buf.String())
+}
+
+func TestFormatOf(t *testing.T) {
+	f, err := FormatOf("out.jsonl")
+	require.NoError(t, err)
+	require.Equal(t, FormatJSON, f)
+
+	_, err = FormatOf("out.xml")
+	require.Error(t, err)
+}
+
+func TestFile(t *testing.T) {
+	st := testStore()
+	path := filepath.Join(t.TempDir(), "out.csv")
+
+	n, err := File(path, st, &query.VectorSelector{Name: "temperature"})
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	// a selector matching nothing must not truncate the existing file.
+	_, err = File(path, st, &query.VectorSelector{Name: "missing"})
+	require.ErrorIs(t, err, ErrNoMatch)
+
+	after, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, data, after)
+}
@@ -0,0 +1,67 @@
+package metric
+
+import "sort"
+
+// SortBins sorts the bins of a histogram by upper bound.
+func SortBins(bins []Bin) {
+	sort.Slice(bins, func(i, j int) bool {
+		return bins[i].Value < bins[j].Value
+	})
+}
+
+// BucketCounts converts cumulative bins, sorted by upper bound, to the number
+// of observations which fall in each bucket.
+func BucketCounts(bins []Bin) []float64 {
+	counts := make([]float64, len(bins))
+
+	var prev uint64
+	for i, b := range bins {
+		if b.Count > prev {
+			counts[i] = float64(b.Count - prev)
+		}
+		prev = max(prev, b.Count)
+	}
+	return counts
+}
+
+// DeltaBins returns the cumulative bins of the observations made between two snapshots
+// of a histogram, whose bins are sorted by upper bound. When the buckets changed, or any
+// count decreased because the histogram was reset, curr is returned as is.
+func DeltaBins(prev, curr []Bin) []Bin {
+	if len(prev) != len(curr) {
+		return curr
+	}
+
+	delta := make([]Bin, len(curr))
+	for i, b := range curr {
+		if b.Value != prev[i].Value || b.Count < prev[i].Count {
+			return curr
+		}
+
+		delta[i] = Bin{
+			Value: b.Value,
+			Count: b.Count - prev[i].Count,
+		}
+	}
+	return delta
+}
+
+// WindowBins returns the cumulative bins of the observations made during the last window
+// milliseconds before the latest of the given snapshots, which must be sorted by timestamp.
+// When the snapshots don't go far enough back, the oldest one is used as the start of the window.
+func WindowBins(snapshots []Histogram, window int64) []Bin {
+	if len(snapshots) == 0 {
+		return nil
+	}
+
+	last := snapshots[len(snapshots)-1]
+
+	first := snapshots[0]
+	for _, h := range snapshots {
+		if h.Timestamp > last.Timestamp-window {
+			break
+		}
+		first = h
+	}
+	return DeltaBins(first.Bins, last.Bins)
+}
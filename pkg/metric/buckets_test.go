@@ -0,0 +1,61 @@
+package metric
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketCounts(t *testing.T) {
+	bins := []Bin{
+		{Value: 0.1, Count: 2},
+		{Value: 0.5, Count: 5},
+		{Value: 1, Count: 5},
+		{Value: math.Inf(1), Count: 6},
+	}
+	require.Equal(t, []float64{2, 3, 0, 1}, BucketCounts(bins))
+}
+
+func TestDeltaBins(t *testing.T) {
+	prev := []Bin{
+		{Value: 0.1, Count: 2},
+		{Value: math.Inf(1), Count: 4},
+	}
+
+	curr := []Bin{
+		{Value: 0.1, Count: 3},
+		{Value: math.Inf(1), Count: 7},
+	}
+
+	require.Equal(t, []Bin{
+		{Value: 0.1, Count: 1},
+		{Value: math.Inf(1), Count: 3},
+	}, DeltaBins(prev, curr))
+
+	// after a reset, counts restart from zero.
+	require.Equal(t, prev, DeltaBins(curr, prev))
+
+	require.Equal(t, curr, DeltaBins(curr[:1], curr))
+}
+
+func TestWindowBins(t *testing.T) {
+	snapshot := func(ts int64, count uint64) Histogram {
+		return Histogram{
+			Bins:      []Bin{{Value: 1, Count: count}, {Value: math.Inf(1), Count: count}},
+			Timestamp: ts,
+		}
+	}
+
+	snapshots := []Histogram{
+		snapshot(1000, 1),
+		snapshot(2000, 3),
+		snapshot(3000, 6),
+		snapshot(4000, 10),
+	}
+
+	require.Equal(t, []Bin{{Value: 1, Count: 7}, {Value: math.Inf(1), Count: 7}}, WindowBins(snapshots, 2000))
+	require.Equal(t, []Bin{{Value: 1, Count: 9}, {Value: math.Inf(1), Count: 9}}, WindowBins(snapshots, 60000))
+	require.Equal(t, []Bin{{Value: 1, Count: 0}, {Value: math.Inf(1), Count: 0}}, WindowBins(snapshots, 0))
+	require.Nil(t, WindowBins(nil, 1000))
+}
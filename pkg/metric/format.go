@@ -0,0 +1,56 @@
+package metric
+
+import (
+	"io"
+	"mime"
+)
+
+// Parser is implemented by the parsers of the supported exposition formats.
+type Parser interface {
+	// Next returns the next sample of the exposition, or io.EOF
+	// when the input is exhausted.
+	Next() (RawMetric, error)
+
+	// Metadata returns the metric families declared so far, indexed by family name.
+	Metadata() map[string]Metadata
+}
+
+// NativeHistogramParser is implemented by the parsers of formats
+// which can carry native histograms.
+type NativeHistogramParser interface {
+	// NativeHistograms returns the native histograms parsed so far,
+	// indexed by their series key.
+	NativeHistograms() map[string]NativeHistogram
+}
+
+const (
+	ContentTypeText        = "text/plain"
+	ContentTypeOpenMetrics = "application/openmetrics-text"
+	ContentTypeProtobuf    = "application/vnd.google.protobuf"
+
+	protobufMessageName = "io.prometheus.client.MetricFamily"
+)
+
+// AcceptHeader is sent when scraping a target to negotiate the exposition format,
+// preferring the delimited protobuf format, then OpenMetrics and finally the classic text format.
+const AcceptHeader = ContentTypeProtobuf + ";proto=" + protobufMessageName + ";encoding=delimited," +
+	"application/openmetrics-text;version=1.0.0;q=0.8," +
+	"application/openmetrics-text;version=0.0.1;q=0.75," +
+	"text/plain;version=0.0.4;q=0.5," +
+	"*/*;q=0.1"
+
+// NewParser returns the parser matching the Content-Type of a scrape response.
+// It falls back to the text format when the content type is missing or unknown.
+func NewParser(contentType string, r io.Reader) Parser {
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+
+	switch mediaType {
+	case ContentTypeOpenMetrics:
+		return NewOpenMetricsParser(r)
+	case ContentTypeProtobuf:
+		if params["proto"] == protobufMessageName && params["encoding"] == "delimited" {
+			return NewProtobufParser(r)
+		}
+	}
+	return NewTextParser(r)
+}
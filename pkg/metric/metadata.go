@@ -0,0 +1,81 @@
+package metric
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type MetricType string
+
+const (
+	TypeUnknown   MetricType = "unknown"
+	TypeCounter   MetricType = "counter"
+	TypeGauge     MetricType = "gauge"
+	TypeHistogram MetricType = "histogram"
+	TypeSummary   MetricType = "summary"
+
+	// OpenMetrics only types
+	TypeGaugeHistogram MetricType = "gaugehistogram"
+	TypeInfo           MetricType = "info"
+	TypeStateSet       MetricType = "stateset"
+)
+
+func ParseMetricType(s string) (MetricType, error) {
+	switch t := MetricType(strings.ToLower(s)); t {
+	case TypeCounter, TypeGauge, TypeHistogram, TypeSummary, TypeUnknown,
+		TypeGaugeHistogram, TypeInfo, TypeStateSet:
+		return t, nil
+	case "untyped":
+		return TypeUnknown, nil
+	}
+	return "", fmt.Errorf("unknown metric type %q", s)
+}
+
+// Metadata holds the information declared by the # HELP, # TYPE
+// and # UNIT lines of a metric family.
+type Metadata struct {
+	Name string
+	Type MetricType
+	Help string
+	Unit string
+}
+
+const (
+	totalSuffix   = "_total"
+	createdSuffix = "_created"
+	infoSuffix    = "_info"
+)
+
+// familySuffixes lists, for each type, the suffixes its series may add to the family name.
+var familySuffixes = map[MetricType][]string{
+	TypeCounter:        {totalSuffix, createdSuffix},
+	TypeHistogram:      {bucketSuffix, countSuffix, sumSuffix, createdSuffix},
+	TypeGaugeHistogram: {bucketSuffix, gcountSuffix, gsumSuffix},
+	TypeSummary:        {countSuffix, sumSuffix, createdSuffix},
+	TypeInfo:           {infoSuffix},
+}
+
+// LookupMetadata returns the metadata of the family the series belongs to.
+// Series such as "foo_bucket" or "foo_total" are matched against the "foo" family
+// when no metadata is declared under their own name.
+func LookupMetadata(metadata map[string]Metadata, name string) (Metadata, bool) {
+	if md, ok := metadata[name]; ok {
+		return md, true
+	}
+
+	idx := strings.LastIndex(name, "_")
+	if idx < 0 {
+		return Metadata{}, false
+	}
+
+	md, ok := metadata[name[:idx]]
+	if !ok {
+		return Metadata{}, false
+	}
+
+	if slices.Contains(familySuffixes[md.Type], name[idx:]) {
+		return md, true
+	}
+	return Metadata{}, false
+}
@@ -3,6 +3,7 @@ package metric
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -15,8 +16,10 @@ type Label struct {
 	Value string
 }
 
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
 func (l *Label) String() string {
-	return fmt.Sprintf(`%s="%s"`, l.Name, l.Value)
+	return fmt.Sprintf(`%s="%s"`, l.Name, labelValueEscaper.Replace(l.Value))
 }
 
 type MetricKey struct {
@@ -45,10 +48,37 @@ func (mk *MetricKey) String() string {
 	return sb.String()
 }
 
+// SortLabels sorts labels by name, which is the order they have in a MetricKey.
+func SortLabels(labels []Label) {
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].Name < labels[j].Name
+	})
+}
+
+// Sample is the value of a series at a point in time.
+type Sample struct {
+	Timestamp int64 // milliseconds since the epoch
+	Value     float64
+}
+
 type RawMetric struct {
 	Name   string
 	Labels []Label
 	Value  float64
+
+	// Timestamp is the optional exposition timestamp, in milliseconds
+	// since the epoch. It is zero when the sample doesn't carry one.
+	Timestamp int64
+
+	// Exemplar is only set by OpenMetrics expositions.
+	Exemplar *Exemplar
+}
+
+// Exemplar references a trace or event which contributed to a sample.
+type Exemplar struct {
+	Labels    []Label
+	Value     float64
+	Timestamp int64
 }
 
 func (m *RawMetric) Find(name string) string {
@@ -75,72 +105,24 @@ func (m *RawMetric) Remove(name string) ([]Label, bool) {
 }
 
 func ParseMetricName(line string) (string, []Label, error) {
-	name, labels, _, err := splitMetricLine(line)
-	return name, labels, err
-}
-
-func ParseMetricLine(line string) (RawMetric, error) {
-	name, labels, valueStr, err := splitMetricLine(line)
-	if err != nil {
-		return RawMetric{}, err
-	}
+	l := &lexer{input: line, line: 1}
 
-	var value float64
-	_, err = fmt.Sscanf(valueStr, "%f", &value)
+	name, err := l.metricName()
 	if err != nil {
-		return RawMetric{}, fmt.Errorf("%w: value is not a number", ErrInvalidMetricLine)
+		return "", nil, err
 	}
 
-	return RawMetric{
-		Name:   name,
-		Labels: labels,
-		Value:  value,
-	}, nil
-}
-
-func splitMetricLine(line string) (string, []Label, string, error) {
-	labelStartIdx := strings.Index(line, "{")
-	labelEndIdx := strings.Index(line, "}")
+	l.skipSpace()
 
 	var labels []Label
-	var name, valueStr string
-
-	if labelStartIdx != -1 && labelEndIdx != -1 && labelEndIdx > labelStartIdx {
-		name = line[:labelStartIdx]
-
-		labels = parseLabels(line[labelStartIdx+1 : labelEndIdx])
-
-		valueStr = line[labelEndIdx+1:]
-	} else {
-		parts := strings.Fields(line)
-		if len(parts) != 2 {
-			return "", nil, "", ErrInvalidMetricLine
-		}
-
-		name = parts[0]
-		valueStr = parts[1]
+	if l.peek() == '{' {
+		labels, err = l.labels()
 	}
-	return name, labels, valueStr, nil
+	return name, labels, err
 }
 
-func parseLabels(labelString string) []Label {
-	var labels []Label
-
-	labelPairs := strings.Split(labelString, ",")
-	for _, pair := range labelPairs {
-		parts := strings.Split(strings.TrimSpace(pair), "=")
-		if len(parts) == 2 {
-			if labels == nil {
-				labels = make([]Label, 0, 1)
-			}
-
-			labels = append(labels, Label{
-				Name:  parts[0],
-				Value: parts[1][1 : len(parts[1])-1],
-			})
-		}
-	}
-	return labels
+func ParseMetricLine(line string) (RawMetric, error) {
+	return parseSample(&lexer{input: line, line: 1}, false)
 }
 
 type Bin struct {
@@ -149,9 +131,26 @@ type Bin struct {
 }
 
 type Histogram struct {
-	Name   string
-	Labels []Label
-	Bins   []Bin
+	Name      string
+	Labels    []Label
+	Bins      []Bin
+	Sum       float64
+	Count     float64
+	Timestamp int64
+}
+
+// Series returns the _bucket, _sum and _count series of the histogram.
+func (h *Histogram) Series() []RawMetric {
+	series := make([]RawMetric, 0, len(h.Bins)+2)
+	for _, b := range h.Bins {
+		labels := append(slices.Clone(h.Labels), Label{Name: "le", Value: FormatFloat(b.Value)})
+		series = append(series, RawMetric{Name: h.Name + bucketSuffix, Labels: labels, Value: float64(b.Count), Timestamp: h.Timestamp})
+	}
+
+	return append(series,
+		RawMetric{Name: h.Name + sumSuffix, Labels: h.Labels, Value: h.Sum, Timestamp: h.Timestamp},
+		RawMetric{Name: h.Name + countSuffix, Labels: h.Labels, Value: h.Count, Timestamp: h.Timestamp},
+	)
 }
 
 type Metrics struct {
@@ -162,10 +161,16 @@ const (
 	bucketSuffix = "_bucket"
 	countSuffix  = "_count"
 	sumSuffix    = "_sum"
+	gcountSuffix = "_gcount"
+	gsumSuffix   = "_gsum"
 )
 
-func ParseHistogram(metrics []RawMetric) (map[string]Histogram, []RawMetric) {
+// ParseHistogram groups the _bucket, _count and _sum series of each histogram.
+// When a family declares its type, only histogram families are grouped; otherwise
+// histograms are recognized by the suffix of their series.
+func ParseHistogram(metrics []RawMetric, metadata map[string]Metadata) (map[string]Histogram, []RawMetric) {
 	type histogramMetrics struct {
+		key     MetricKey
 		buckets []RawMetric
 		count   *RawMetric
 		sum     *RawMetric
@@ -175,12 +180,10 @@ func ParseHistogram(metrics []RawMetric) (map[string]Histogram, []RawMetric) {
 
 	histograms := make(map[string]histogramMetrics)
 	for _, m := range metrics {
-		name := trimHistogramSuffix(m.Name)
-		if len(name) < len(m.Name) {
+		name := TrimHistogramSuffix(m.Name)
+		if len(name) < len(m.Name) && isHistogramSeries(m.Name, name, metadata) {
 			labels, _ := m.Remove("le")
-			sort.Slice(labels, func(i, j int) bool {
-				return labels[i].Name < labels[j].Name
-			})
+			SortLabels(labels)
 
 			mk := MetricKey{
 				Name:   name,
@@ -189,13 +192,14 @@ func ParseHistogram(metrics []RawMetric) (map[string]Histogram, []RawMetric) {
 
 			s := mk.String()
 			h := histograms[s]
+			h.key = mk
 
 			switch {
 			case strings.HasSuffix(m.Name, bucketSuffix):
 				h.buckets = append(h.buckets, m)
-			case strings.HasSuffix(m.Name, countSuffix):
+			case strings.HasSuffix(m.Name, countSuffix), strings.HasSuffix(m.Name, gcountSuffix):
 				h.count = &m
-			case strings.HasSuffix(m.Name, sumSuffix):
+			case strings.HasSuffix(m.Name, sumSuffix), strings.HasSuffix(m.Name, gsumSuffix):
 				h.sum = &m
 			}
 			histograms[s] = h
@@ -206,11 +210,6 @@ func ParseHistogram(metrics []RawMetric) (map[string]Histogram, []RawMetric) {
 
 	out := make(map[string]Histogram, len(histograms))
 	for k, hist := range histograms {
-		name, labels, _, err := splitMetricLine(k + " 0")
-		if err != nil {
-			panic("unexpected err" + err.Error())
-		}
-
 		release := func(h *histogramMetrics) {
 			if h.count != nil {
 				filteredMetrics = append(filteredMetrics, *h.count)
@@ -227,9 +226,12 @@ func ParseHistogram(metrics []RawMetric) (map[string]Histogram, []RawMetric) {
 			bins, err := parseHistogramBins(hist.buckets)
 			if err == nil {
 				out[k] = Histogram{
-					Name:   name,
-					Labels: labels,
-					Bins:   bins,
+					Name:      hist.key.Name,
+					Labels:    hist.key.Labels,
+					Bins:      bins,
+					Sum:       hist.sum.Value,
+					Count:     hist.count.Value,
+					Timestamp: hist.count.Timestamp,
 				}
 			} else {
 				release(&hist)
@@ -260,12 +262,31 @@ func parseHistogramBins(bucketMetrics []RawMetric) ([]Bin, error) {
 			Count: uint64(b.Value),
 		}
 	}
+
+	SortBins(buckets)
 	return buckets, nil
 }
 
-func trimHistogramSuffix(s string) string {
-	s = strings.TrimSuffix(s, bucketSuffix)
-	s = strings.TrimSuffix(s, countSuffix)
-	s = strings.TrimSuffix(s, sumSuffix)
+func isHistogramSeries(name, family string, metadata map[string]Metadata) bool {
+	if md, ok := metadata[family]; ok {
+		return md.Type == TypeHistogram || md.Type == TypeGaugeHistogram
+	}
+
+	// the series belongs to a family declared under its own name
+	// (e.g. a "foo_count" counter).
+	if _, ok := metadata[name]; ok {
+		return false
+	}
+	return true
+}
+
+// TrimHistogramSuffix returns the name of the histogram a series belongs to,
+// or the name itself if it isn't the name of a histogram series.
+func TrimHistogramSuffix(s string) string {
+	for _, suffix := range []string{bucketSuffix, countSuffix, sumSuffix, gcountSuffix, gsumSuffix} {
+		if strings.HasSuffix(s, suffix) {
+			return strings.TrimSuffix(s, suffix)
+		}
+	}
 	return s
 }
@@ -1,6 +1,7 @@
 package metric
 
 import (
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -145,6 +146,67 @@ func TestParseMetricLine(t *testing.T) {
 			},
 			expectedErr: nil,
 		},
+		{
+			line: `http_requests_total{path="/a,b{c}=d", msg="say \"hi\"\\n", nl="a\nb"} 3 1712000000000`,
+			expectedMetric: RawMetric{
+				Name: "http_requests_total",
+				Labels: []Label{
+					{
+						Name:  "path",
+						Value: "/a,b{c}=d",
+					},
+					{
+						Name:  "msg",
+						Value: `say "hi"\n`,
+					},
+					{
+						Name:  "nl",
+						Value: "a\nb",
+					},
+				},
+				Value:     3,
+				Timestamp: 1712000000000,
+			},
+			expectedErr: nil,
+		},
+		{
+			line: `rpc_duration_seconds_bucket{le="+Inf",} +Inf`,
+			expectedMetric: RawMetric{
+				Name: "rpc_duration_seconds_bucket",
+				Labels: []Label{
+					{
+						Name:  "le",
+						Value: "+Inf",
+					},
+				},
+				Value: math.Inf(1),
+			},
+			expectedErr: nil,
+		},
+		{
+			line: `temperature_celsius -Inf -1`,
+			expectedMetric: RawMetric{
+				Name:      "temperature_celsius",
+				Value:     math.Inf(-1),
+				Timestamp: -1,
+			},
+			expectedErr: nil,
+		},
+		{
+			line:           `http_requests_total{method="get} 1`,
+			expectedMetric: RawMetric{},
+			expectedErr:    ErrInvalidMetricLine,
+		},
+		{
+			line:           `http_requests_total 1 2 3`,
+			expectedMetric: RawMetric{},
+			expectedErr:    ErrInvalidMetricLine,
+		},
+		{
+			line:           `http_requests_total 1 1.5`,
+			expectedMetric: RawMetric{},
+			expectedErr:    ErrInvalidMetricLine,
+		},
 		{
 			line: `cpu_usage 75.5`,
 			expectedMetric: RawMetric{
@@ -169,3 +231,34 @@ func TestParseMetricLine(t *testing.T) {
 		})
 	}
 }
+
+func TestParseHistogram(t *testing.T) {
+	metrics := []RawMetric{
+		{Name: "latency_seconds_bucket", Labels: []Label{{Name: "le", Value: "0.1"}}, Value: 1},
+		{Name: "latency_seconds_bucket", Labels: []Label{{Name: "le", Value: "+Inf"}}, Value: 3},
+		{Name: "latency_seconds_sum", Value: 0.5},
+		{Name: "latency_seconds_count", Value: 3},
+		{Name: "jobs_count", Value: 7},
+		{Name: "jobs_sum", Value: 7},
+	}
+
+	t.Run("by suffix", func(t *testing.T) {
+		hists, rem := ParseHistogram(metrics, nil)
+
+		require.Len(t, hists, 1)
+		require.Equal(t, "latency_seconds", hists["latency_seconds"].Name)
+		require.Equal(t, []Bin{{Value: 0.1, Count: 1}, {Value: math.Inf(1), Count: 3}}, hists["latency_seconds"].Bins)
+		require.Len(t, rem, 2)
+	})
+
+	t.Run("by type", func(t *testing.T) {
+		metadata := map[string]Metadata{
+			"latency_seconds": {Name: "latency_seconds", Type: TypeGauge},
+			"jobs_count":      {Name: "jobs_count", Type: TypeCounter},
+		}
+
+		hists, rem := ParseHistogram(metrics, metadata)
+		require.Empty(t, hists)
+		require.Len(t, rem, len(metrics))
+	})
+}
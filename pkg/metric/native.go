@@ -0,0 +1,163 @@
+package metric
+
+import "math"
+
+// BucketSpan describes a run of consecutive buckets of a native histogram.
+// Offset is the gap from the end of the previous span or, for the first span,
+// the index of its first bucket.
+type BucketSpan struct {
+	Offset int32
+	Length uint32
+}
+
+// NativeHistogram is a sparse histogram with exponential buckets, whose
+// boundaries are determined by the schema: each bucket is wider than the
+// previous one by a factor of 2^(2^-schema).
+type NativeHistogram struct {
+	Name   string
+	Labels []Label
+
+	Schema        int32
+	ZeroThreshold float64
+	ZeroCount     float64
+	Count         float64
+	Sum           float64
+
+	PositiveSpans   []BucketSpan
+	PositiveBuckets []float64 // absolute counts, one for each bucket of the spans
+	NegativeSpans   []BucketSpan
+	NegativeBuckets []float64
+
+	Timestamp int64
+}
+
+// NativeBucket is a bucket of a native histogram covering the (Lower, Upper] interval.
+type NativeBucket struct {
+	Lower float64
+	Upper float64
+	Count float64
+}
+
+// MinNativeSchema is the lowest resolution a native histogram can be reduced to.
+const MinNativeSchema = -4
+
+// Buckets returns the buckets described by the spans of the histogram,
+// including the zero bucket, ordered by their bounds.
+func (h *NativeHistogram) Buckets() []NativeBucket {
+	buckets := make([]NativeBucket, 0, len(h.NegativeBuckets)+len(h.PositiveBuckets)+1)
+
+	idx, counts := expandBuckets(h.NegativeSpans, h.NegativeBuckets)
+	for i := len(idx) - 1; i >= 0; i-- {
+		buckets = append(buckets, NativeBucket{
+			Lower: -bucketUpperBound(idx[i], h.Schema),
+			Upper: -bucketUpperBound(idx[i]-1, h.Schema),
+			Count: counts[i],
+		})
+	}
+
+	if h.ZeroCount > 0 || h.ZeroThreshold > 0 {
+		buckets = append(buckets, NativeBucket{
+			Lower: -h.ZeroThreshold,
+			Upper: h.ZeroThreshold,
+			Count: h.ZeroCount,
+		})
+	}
+
+	idx, counts = expandBuckets(h.PositiveSpans, h.PositiveBuckets)
+	for i := range idx {
+		buckets = append(buckets, NativeBucket{
+			Lower: bucketUpperBound(idx[i]-1, h.Schema),
+			Upper: bucketUpperBound(idx[i], h.Schema),
+			Count: counts[i],
+		})
+	}
+	return buckets
+}
+
+// Reduce returns a copy of the histogram at a lower resolution schema,
+// obtained by merging adjacent buckets.
+func (h *NativeHistogram) Reduce(schema int32) *NativeHistogram {
+	out := *h
+	if schema >= h.Schema {
+		return &out
+	}
+
+	out.Schema = schema
+	out.PositiveSpans, out.PositiveBuckets = reduceBuckets(h.PositiveSpans, h.PositiveBuckets, h.Schema-schema)
+	out.NegativeSpans, out.NegativeBuckets = reduceBuckets(h.NegativeSpans, h.NegativeBuckets, h.Schema-schema)
+	return &out
+}
+
+func reduceBuckets(spans []BucketSpan, counts []float64, delta int32) ([]BucketSpan, []float64) {
+	idx, counts := expandBuckets(spans, counts)
+
+	var outIdx []int32
+	var outCounts []float64
+	for i := range idx {
+		target := ((idx[i] - 1) >> delta) + 1
+
+		if n := len(outIdx); n > 0 && outIdx[n-1] == target {
+			outCounts[n-1] += counts[i]
+			continue
+		}
+
+		outIdx = append(outIdx, target)
+		outCounts = append(outCounts, counts[i])
+	}
+	return compactBuckets(outIdx, outCounts)
+}
+
+// expandBuckets returns the index of each bucket described by the spans.
+func expandBuckets(spans []BucketSpan, counts []float64) ([]int32, []float64) {
+	idx := make([]int32, 0, len(counts))
+
+	var next int32
+	for _, s := range spans {
+		next += s.Offset
+		for j := uint32(0); j < s.Length; j++ {
+			idx = append(idx, next)
+			next++
+		}
+	}
+
+	n := min(len(idx), len(counts))
+	return idx[:n], counts[:n]
+}
+
+// compactBuckets is the inverse of expandBuckets: idx must be sorted.
+func compactBuckets(idx []int32, counts []float64) ([]BucketSpan, []float64) {
+	var spans []BucketSpan
+
+	var next int32
+	for i, bi := range idx {
+		if i > 0 && bi == next {
+			spans[len(spans)-1].Length++
+		} else {
+			spans = append(spans, BucketSpan{Offset: bi - next, Length: 1})
+		}
+		next = bi + 1
+	}
+	return spans, counts
+}
+
+// bucketUpperBound returns the upper bound of the bucket with the given index,
+// that is 2^(idx * 2^-schema).
+func bucketUpperBound(idx int32, schema int32) float64 {
+	if schema <= 0 {
+		exp := int(idx) << -schema
+		return math.Ldexp(1, exp)
+	}
+	return math.Exp2(float64(idx) * math.Exp2(-float64(schema)))
+}
+
+// DeltasToCounts converts the delta encoded bucket counts of the protobuf format to absolute counts.
+func DeltasToCounts(deltas []int64) []float64 {
+	counts := make([]float64, len(deltas))
+
+	var curr int64
+	for i, d := range deltas {
+		curr += d
+		counts[i] = float64(curr)
+	}
+	return counts
+}
@@ -0,0 +1,45 @@
+package metric
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNativeHistogramBuckets(t *testing.T) {
+	h := NativeHistogram{
+		Schema:          0,
+		ZeroThreshold:   0.001,
+		ZeroCount:       1,
+		PositiveSpans:   []BucketSpan{{Offset: 0, Length: 2}, {Offset: 1, Length: 1}},
+		PositiveBuckets: []float64{2, 3, 4},
+		NegativeSpans:   []BucketSpan{{Offset: 1, Length: 1}},
+		NegativeBuckets: []float64{5},
+	}
+
+	require.Equal(t, []NativeBucket{
+		{Lower: -2, Upper: -1, Count: 5},
+		{Lower: -0.001, Upper: 0.001, Count: 1},
+		{Lower: 0.5, Upper: 1, Count: 2},
+		{Lower: 1, Upper: 2, Count: 3},
+		{Lower: 4, Upper: 8, Count: 4},
+	}, h.Buckets())
+
+	r := h.Reduce(-1)
+	require.Equal(t, int32(-1), r.Schema)
+	require.Equal(t, []NativeBucket{
+		{Lower: -4, Upper: -1, Count: 5},
+		{Lower: -0.001, Upper: 0.001, Count: 1},
+		{Lower: 0.25, Upper: 1, Count: 2},
+		{Lower: 1, Upper: 4, Count: 3},
+		{Lower: 4, Upper: 16, Count: 4},
+	}, r.Buckets())
+
+	r = h.Reduce(-2)
+	require.Equal(t, []BucketSpan{{Offset: 0, Length: 2}}, r.PositiveSpans)
+	require.Equal(t, []float64{2, 7}, r.PositiveBuckets)
+}
+
+func TestDeltasToCounts(t *testing.T) {
+	require.Equal(t, []float64{2, 5, 1}, DeltasToCounts([]int64{2, 3, -4}))
+}
@@ -0,0 +1,523 @@
+package metric
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParseError describes a malformed line of an exposition, along with
+// the position at which parsing stopped. It wraps ErrInvalidMetricLine.
+type ParseError struct {
+	Line int
+	Col  int
+	Msg  string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d, col %d: %s", e.Line, e.Col, e.Msg)
+}
+
+func (e *ParseError) Unwrap() error {
+	return ErrInvalidMetricLine
+}
+
+// ErrMissingEOF is returned when an OpenMetrics exposition is not terminated by "# EOF",
+// which usually means that it has been truncated.
+var ErrMissingEOF = errors.New("missing # EOF terminator")
+
+const maxLineSize = 1024 * 1024
+
+// TextParser parses the Prometheus text exposition format (version 0.0.4)
+// or, when created by NewOpenMetricsParser, the OpenMetrics text format.
+type TextParser struct {
+	sc   *bufio.Scanner
+	line int
+
+	openMetrics bool
+	done        bool
+
+	metadata map[string]Metadata
+}
+
+func NewTextParser(r io.Reader) *TextParser {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	return &TextParser{
+		sc:       sc,
+		metadata: make(map[string]Metadata),
+	}
+}
+
+// NewOpenMetricsParser returns a parser for the OpenMetrics text format, which adds
+// units, exemplars, timestamps in seconds and a mandatory "# EOF" terminator.
+func NewOpenMetricsParser(r io.Reader) *TextParser {
+	p := NewTextParser(r)
+	p.openMetrics = true
+	return p
+}
+
+// Metadata returns the metric families declared by the # HELP and # TYPE lines
+// parsed so far, indexed by family name.
+func (p *TextParser) Metadata() map[string]Metadata {
+	return p.metadata
+}
+
+// Next returns the next sample of the exposition, skipping blank lines and comments.
+// It returns io.EOF when the input is exhausted. Malformed lines are reported
+// as a *ParseError, after which Next can be called again to resume parsing.
+func (p *TextParser) Next() (RawMetric, error) {
+	for !p.done && p.sc.Scan() {
+		p.line++
+
+		l := &lexer{input: p.sc.Text(), line: p.line}
+		l.skipSpace()
+
+		if l.eof() {
+			continue
+		}
+
+		if l.peek() == '#' {
+			if err := p.parseComment(l); err != nil {
+				return RawMetric{}, err
+			}
+			continue
+		}
+		return parseSample(l, p.openMetrics)
+	}
+
+	if err := p.sc.Err(); err != nil {
+		return RawMetric{}, err
+	}
+
+	if p.openMetrics && !p.done {
+		return RawMetric{}, ErrMissingEOF
+	}
+	return RawMetric{}, io.EOF
+}
+
+// parseComment records the metadata declared by # HELP, # TYPE and # UNIT lines.
+// Any other comment is ignored.
+func (p *TextParser) parseComment(l *lexer) error {
+	l.pos++
+	l.skipSpace()
+
+	keyword := l.field()
+	switch {
+	case keyword == "HELP", keyword == "TYPE":
+	case keyword == "UNIT" && p.openMetrics:
+	case keyword == "EOF" && p.openMetrics:
+		p.done = true
+		return nil
+	default:
+		return nil
+	}
+
+	l.skipSpace()
+
+	name, err := l.metricName()
+	if err != nil {
+		return err
+	}
+
+	md := p.metadata[name]
+	md.Name = name
+	if md.Type == "" {
+		md.Type = TypeUnknown
+	}
+
+	switch keyword {
+	case "HELP":
+		if isSpace(l.peek()) {
+			l.pos++
+		}
+
+		md.Help = l.docstring(p.openMetrics)
+	case "UNIT":
+		l.skipSpace()
+		md.Unit = l.field()
+	case "TYPE":
+		l.skipSpace()
+
+		start := l.pos
+		md.Type, err = ParseMetricType(l.field())
+		if err != nil {
+			l.pos = start
+			return l.errorf("%s", err)
+		}
+
+		l.skipSpace()
+		if !l.eof() {
+			return l.errorf("unexpected %q after metric type", l.input[l.pos:])
+		}
+	}
+
+	p.metadata[name] = md
+	return nil
+}
+
+func parseSample(l *lexer, openMetrics bool) (RawMetric, error) {
+	name, err := l.metricName()
+	if err != nil {
+		return RawMetric{}, err
+	}
+
+	l.skipSpace()
+
+	var labels []Label
+	if l.peek() == '{' {
+		labels, err = l.labels()
+		if err != nil {
+			return RawMetric{}, err
+		}
+	}
+
+	l.skipSpace()
+
+	value, err := l.value()
+	if err != nil {
+		return RawMetric{}, err
+	}
+
+	l.skipSpace()
+
+	var ts int64
+	if !l.eof() && !(openMetrics && l.peek() == '#') {
+		ts, err = l.timestamp(openMetrics)
+		if err != nil {
+			return RawMetric{}, err
+		}
+	}
+
+	l.skipSpace()
+
+	var exemplar *Exemplar
+	if openMetrics && l.peek() == '#' {
+		exemplar, err = l.exemplar()
+		if err != nil {
+			return RawMetric{}, err
+		}
+	}
+
+	l.skipSpace()
+	if !l.eof() {
+		return RawMetric{}, l.errorf("unexpected %q after sample", l.input[l.pos:])
+	}
+
+	return RawMetric{
+		Name:      name,
+		Labels:    labels,
+		Value:     value,
+		Timestamp: ts,
+		Exemplar:  exemplar,
+	}, nil
+}
+
+// lexer scans a single line of an exposition.
+type lexer struct {
+	input string
+	pos   int
+	line  int
+}
+
+func (l *lexer) errorf(format string, args ...any) error {
+	return &ParseError{
+		Line: l.line,
+		Col:  l.pos + 1,
+		Msg:  fmt.Sprintf(format, args...),
+	}
+}
+
+func (l *lexer) eof() bool {
+	return l.pos >= len(l.input)
+}
+
+func (l *lexer) peek() byte {
+	if l.eof() {
+		return 0
+	}
+	return l.input[l.pos]
+}
+
+func (l *lexer) skipSpace() {
+	for !l.eof() && isSpace(l.peek()) {
+		l.pos++
+	}
+}
+
+func (l *lexer) expect(c byte) error {
+	if l.peek() != c {
+		if l.eof() {
+			return l.errorf("expected %q, found end of line", c)
+		}
+		return l.errorf("expected %q, found %q", c, l.peek())
+	}
+	l.pos++
+	return nil
+}
+
+// field returns the next run of non blank characters.
+func (l *lexer) field() string {
+	start := l.pos
+	for !l.eof() && !isSpace(l.peek()) {
+		l.pos++
+	}
+	return l.input[start:l.pos]
+}
+
+func (l *lexer) metricName() (string, error) {
+	return l.identifier("metric name", true)
+}
+
+func (l *lexer) labelName() (string, error) {
+	return l.identifier("label name", false)
+}
+
+func (l *lexer) identifier(what string, allowColon bool) (string, error) {
+	start := l.pos
+	for !l.eof() {
+		c := l.peek()
+		if !isNameChar(c, l.pos == start) && !(allowColon && c == ':') {
+			break
+		}
+		l.pos++
+	}
+
+	if l.pos == start {
+		if l.eof() {
+			return "", l.errorf("missing %s", what)
+		}
+		return "", l.errorf("invalid character %q in %s", l.peek(), what)
+	}
+	return l.input[start:l.pos], nil
+}
+
+func (l *lexer) labels() ([]Label, error) {
+	if err := l.expect('{'); err != nil {
+		return nil, err
+	}
+
+	var labels []Label
+	for {
+		l.skipSpace()
+		if l.peek() == '}' {
+			l.pos++
+			return labels, nil
+		}
+
+		name, err := l.labelName()
+		if err != nil {
+			return nil, err
+		}
+
+		l.skipSpace()
+		if err := l.expect('='); err != nil {
+			return nil, err
+		}
+		l.skipSpace()
+
+		value, err := l.quoted()
+		if err != nil {
+			return nil, err
+		}
+
+		labels = append(labels, Label{
+			Name:  name,
+			Value: value,
+		})
+
+		l.skipSpace()
+		switch l.peek() {
+		case ',':
+			l.pos++
+		case '}':
+		default:
+			if l.eof() {
+				return nil, l.errorf("unterminated label set")
+			}
+			return nil, l.errorf("expected ',' or '}', found %q", l.peek())
+		}
+	}
+}
+
+// quoted scans a double quoted string, resolving the \\, \" and \n escape sequences.
+func (l *lexer) quoted() (string, error) {
+	if err := l.expect('"'); err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for !l.eof() {
+		c := l.peek()
+		l.pos++
+
+		switch c {
+		case '"':
+			return sb.String(), nil
+		case '\\':
+			if l.eof() {
+				return "", l.errorf("unterminated escape sequence")
+			}
+
+			switch e := l.peek(); e {
+			case '\\', '"':
+				sb.WriteByte(e)
+			case 'n':
+				sb.WriteByte('\n')
+			default:
+				return "", l.errorf("invalid escape sequence \"\\%c\"", e)
+			}
+			l.pos++
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", l.errorf("unterminated quoted string")
+}
+
+// docstring returns the remainder of the line, resolving the \\ and \n escape sequences.
+// OpenMetrics also allows escaping double quotes.
+func (l *lexer) docstring(openMetrics bool) string {
+	var sb strings.Builder
+	for !l.eof() {
+		c := l.peek()
+		l.pos++
+
+		if c != '\\' {
+			sb.WriteByte(c)
+			continue
+		}
+
+		switch l.peek() {
+		case '\\':
+			sb.WriteByte('\\')
+		case '"':
+			if !openMetrics {
+				sb.WriteByte(c)
+				continue
+			}
+			sb.WriteByte('"')
+		case 'n':
+			sb.WriteByte('\n')
+		default:
+			// unknown escape sequences are kept as they are
+			sb.WriteByte(c)
+			continue
+		}
+		l.pos++
+	}
+	return sb.String()
+}
+
+func (l *lexer) value() (float64, error) {
+	start := l.pos
+
+	s := l.field()
+	if s == "" {
+		return 0, l.errorf("missing value")
+	}
+
+	v, err := parseFloat(s)
+	if err != nil {
+		l.pos = start
+		return 0, l.errorf("value %q is not a number", s)
+	}
+	return v, nil
+}
+
+// timestamp returns the timestamp of a sample in milliseconds.
+// OpenMetrics timestamps are expressed in (possibly fractional) seconds.
+func (l *lexer) timestamp(openMetrics bool) (int64, error) {
+	start := l.pos
+
+	s := l.field()
+	if openMetrics {
+		secs, err := parseFloat(s)
+		if err != nil || math.IsNaN(secs) || math.IsInf(secs, 0) {
+			l.pos = start
+			return 0, l.errorf("timestamp %q is not a number", s)
+		}
+		return int64(math.Round(secs * 1000)), nil
+	}
+
+	ts, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		l.pos = start
+		return 0, l.errorf("timestamp %q is not an integer", s)
+	}
+	return ts, nil
+}
+
+// exemplar scans the "# {labels} value [timestamp]" suffix of an OpenMetrics sample.
+func (l *lexer) exemplar() (*Exemplar, error) {
+	if err := l.expect('#'); err != nil {
+		return nil, err
+	}
+
+	l.skipSpace()
+
+	labels, err := l.labels()
+	if err != nil {
+		return nil, err
+	}
+
+	l.skipSpace()
+
+	value, err := l.value()
+	if err != nil {
+		return nil, err
+	}
+
+	l.skipSpace()
+
+	var ts int64
+	if !l.eof() {
+		ts, err = l.timestamp(true)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Exemplar{
+		Labels:    labels,
+		Value:     value,
+		Timestamp: ts,
+	}, nil
+}
+
+func parseFloat(s string) (float64, error) {
+	switch s {
+	case "NaN":
+		return math.NaN(), nil
+	case "+Inf", "Inf":
+		return math.Inf(1), nil
+	case "-Inf":
+		return math.Inf(-1), nil
+	}
+
+	// ParseFloat also accepts spellings such as "infinity" or "0x1p-2",
+	// which are not valid in an exposition.
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9') && c != '.' && c != 'e' && c != 'E' && c != '+' && c != '-' {
+			return 0, strconv.ErrSyntax
+		}
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+func isNameChar(c byte, first bool) bool {
+	return (c >= 'a' && c <= 'z') ||
+		(c >= 'A' && c <= 'Z') ||
+		c == '_' ||
+		(!first && c >= '0' && c <= '9')
+}
@@ -0,0 +1,207 @@
+package metric
+
+import (
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTextParser(t *testing.T) {
+	input := `# HELP go_goroutines Number of goroutines that currently exist.
+# TYPE go_goroutines gauge
+go_goroutines 42
+
+kube_pod_labels{namespace="default",label_app="a,b=c"} 1
+	process_start_time_seconds   1.7e+09
+up{job="x"} NaN
+broken{job="x" 1
+`
+
+	p := NewTextParser(strings.NewReader(input))
+
+	var metrics []RawMetric
+	var errs []error
+	for {
+		m, err := p.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		metrics = append(metrics, m)
+	}
+
+	require.Len(t, metrics, 4)
+	require.Equal(t, RawMetric{Name: "go_goroutines", Value: 42}, metrics[0])
+	require.Equal(t, []Label{
+		{Name: "namespace", Value: "default"},
+		{Name: "label_app", Value: "a,b=c"},
+	}, metrics[1].Labels)
+	require.Equal(t, 1.7e9, metrics[2].Value)
+	require.True(t, math.IsNaN(metrics[3].Value))
+
+	require.Len(t, errs, 1)
+
+	var perr *ParseError
+	require.ErrorAs(t, errs[0], &perr)
+	require.ErrorIs(t, errs[0], ErrInvalidMetricLine)
+	require.Equal(t, 8, perr.Line)
+	require.Equal(t, 16, perr.Col)
+}
+
+func TestParseErrorPosition(t *testing.T) {
+	cases := []struct {
+		line string
+		col  int
+	}{
+		{line: `{job="x"} 1`, col: 1},
+		{line: `up{job=x} 1`, col: 8},
+		{line: `up{job="x\q"} 1`, col: 11},
+		{line: `up{job="x"} one`, col: 13},
+		{line: `up 1 now`, col: 6},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.line, func(t *testing.T) {
+			_, err := ParseMetricLine(tc.line)
+
+			var perr *ParseError
+			require.ErrorAs(t, err, &perr)
+			require.Equal(t, 1, perr.Line)
+			require.Equal(t, tc.col, perr.Col)
+		})
+	}
+}
+
+func TestTextParserMetadata(t *testing.T) {
+	input := `# HELP http_requests_total Total number of HTTP requests.\nSee docs at C:\\docs.
+# TYPE http_requests_total counter
+http_requests_total 10
+# TYPE rpc_duration_seconds histogram
+# a plain comment
+# TYPE queue_length untyped
+# TYPE broken flavour
+`
+
+	p := NewTextParser(strings.NewReader(input))
+
+	var errs []error
+	for {
+		_, err := p.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	require.Len(t, errs, 1)
+	require.ErrorIs(t, errs[0], ErrInvalidMetricLine)
+
+	require.Equal(t, map[string]Metadata{
+		"http_requests_total": {
+			Name: "http_requests_total",
+			Type: TypeCounter,
+			Help: "Total number of HTTP requests.\nSee docs at C:\\docs.",
+		},
+		"rpc_duration_seconds": {
+			Name: "rpc_duration_seconds",
+			Type: TypeHistogram,
+		},
+		"queue_length": {
+			Name: "queue_length",
+			Type: TypeUnknown,
+		},
+	}, p.Metadata())
+
+	md, ok := LookupMetadata(p.Metadata(), "rpc_duration_seconds_bucket")
+	require.True(t, ok)
+	require.Equal(t, TypeHistogram, md.Type)
+
+	_, ok = LookupMetadata(p.Metadata(), "queue_length_sum")
+	require.False(t, ok)
+}
+
+func TestOpenMetricsParser(t *testing.T) {
+	input := `# TYPE http_requests counter
+# UNIT http_requests requests
+# HELP http_requests Total \"handled\" requests.
+http_requests_total{code="200"} 1027 1520879607.789 # {trace_id="KOO5S4vxi0o"} 0.67 1520879606
+http_requests_created{code="200"} 1520430000.123
+# TYPE build info
+build_info{version="1.2"} 1
+# TYPE feature stateset
+feature{feature="a"} 1
+# EOF
+`
+
+	p := NewParser("application/openmetrics-text; version=1.0.0; charset=utf-8", strings.NewReader(input))
+
+	var metrics []RawMetric
+	for {
+		m, err := p.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+
+		metrics = append(metrics, m)
+	}
+
+	require.Len(t, metrics, 4)
+	require.Equal(t, RawMetric{
+		Name:      "http_requests_total",
+		Labels:    []Label{{Name: "code", Value: "200"}},
+		Value:     1027,
+		Timestamp: 1520879607789,
+		Exemplar: &Exemplar{
+			Labels:    []Label{{Name: "trace_id", Value: "KOO5S4vxi0o"}},
+			Value:     0.67,
+			Timestamp: 1520879606000,
+		},
+	}, metrics[0])
+	require.Equal(t, 1520430000.123, metrics[1].Value)
+
+	md, ok := LookupMetadata(p.Metadata(), "http_requests_total")
+	require.True(t, ok)
+	require.Equal(t, Metadata{
+		Name: "http_requests",
+		Type: TypeCounter,
+		Help: `Total "handled" requests.`,
+		Unit: "requests",
+	}, md)
+
+	md, ok = LookupMetadata(p.Metadata(), "build_info")
+	require.True(t, ok)
+	require.Equal(t, TypeInfo, md.Type)
+	require.Equal(t, TypeStateSet, p.Metadata()["feature"].Type)
+}
+
+func TestOpenMetricsParserMissingEOF(t *testing.T) {
+	p := NewOpenMetricsParser(strings.NewReader("up 1\n"))
+
+	_, err := p.Next()
+	require.NoError(t, err)
+
+	_, err = p.Next()
+	require.ErrorIs(t, err, ErrMissingEOF)
+}
+
+func TestNewParserFallback(t *testing.T) {
+	p := NewParser("", strings.NewReader("up 1 # {a=\"b\"} 1\n"))
+
+	_, err := p.Next()
+	require.ErrorIs(t, err, ErrInvalidMetricLine)
+
+	_, err = p.Next()
+	require.ErrorIs(t, err, io.EOF)
+}
@@ -0,0 +1,258 @@
+package metric
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protodelim"
+)
+
+var ErrInvalidProtobuf = errors.New("invalid protobuf message")
+
+// ProtobufParser decodes the delimited protobuf exposition format, where each
+// io.prometheus.client.MetricFamily message is prefixed by its varint encoded length.
+// Families are flattened into the same series exposed by the text format,
+// so that histograms and summaries can be grouped as usual.
+type ProtobufParser struct {
+	r *bufio.Reader
+
+	pending  []RawMetric
+	metadata map[string]Metadata
+	natives  map[string]NativeHistogram
+}
+
+func NewProtobufParser(r io.Reader) *ProtobufParser {
+	return &ProtobufParser{
+		r:        bufio.NewReader(r),
+		metadata: make(map[string]Metadata),
+		natives:  make(map[string]NativeHistogram),
+	}
+}
+
+func (p *ProtobufParser) Metadata() map[string]Metadata {
+	return p.metadata
+}
+
+func (p *ProtobufParser) NativeHistograms() map[string]NativeHistogram {
+	return p.natives
+}
+
+func (p *ProtobufParser) Next() (RawMetric, error) {
+	for len(p.pending) == 0 {
+		var mf dto.MetricFamily
+
+		err := protodelim.UnmarshalOptions{MaxSize: -1}.UnmarshalFrom(p.r, &mf)
+		if errors.Is(err, io.EOF) {
+			return RawMetric{}, io.EOF
+		}
+
+		if err != nil {
+			return RawMetric{}, fmt.Errorf("%w: %s", ErrInvalidProtobuf, err)
+		}
+
+		p.addFamily(&mf)
+	}
+
+	m := p.pending[0]
+	p.pending = p.pending[1:]
+	return m, nil
+}
+
+func (p *ProtobufParser) addFamily(mf *dto.MetricFamily) {
+	name := mf.GetName()
+
+	p.metadata[name] = Metadata{
+		Name: name,
+		Type: protobufMetricType(mf.GetType()),
+		Help: mf.GetHelp(),
+		Unit: mf.GetUnit(),
+	}
+
+	for _, m := range mf.GetMetric() {
+		labels := protobufLabels(m.GetLabel())
+
+		add := func(name string, value float64, extra ...Label) {
+			p.pending = append(p.pending, RawMetric{
+				Name:      name,
+				Labels:    append(labels[:len(labels):len(labels)], extra...),
+				Value:     value,
+				Timestamp: m.GetTimestampMs(),
+			})
+		}
+
+		switch mf.GetType() {
+		case dto.MetricType_COUNTER:
+			add(name, m.GetCounter().GetValue())
+			p.pending[len(p.pending)-1].Exemplar = protobufExemplar(m.GetCounter().GetExemplar())
+		case dto.MetricType_GAUGE:
+			add(name, m.GetGauge().GetValue())
+		case dto.MetricType_UNTYPED:
+			add(name, m.GetUntyped().GetValue())
+		case dto.MetricType_SUMMARY:
+			s := m.GetSummary()
+			for _, q := range s.GetQuantile() {
+				add(name, q.GetValue(), Label{Name: "quantile", Value: FormatFloat(q.GetQuantile())})
+			}
+			add(name+sumSuffix, s.GetSampleSum())
+			add(name+countSuffix, float64(s.GetSampleCount()))
+		case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+			h := m.GetHistogram()
+			if isNativeHistogram(h) {
+				p.addNativeHistogram(name, labels, m.GetTimestampMs(), h)
+			}
+
+			count := float64(h.GetSampleCount())
+			if h.SampleCountFloat != nil {
+				count = h.GetSampleCountFloat()
+			}
+
+			hasInf := false
+			for _, b := range h.GetBucket() {
+				bucketCount := float64(b.GetCumulativeCount())
+				if b.CumulativeCountFloat != nil {
+					bucketCount = b.GetCumulativeCountFloat()
+				}
+
+				add(name+bucketSuffix, bucketCount, Label{Name: "le", Value: FormatFloat(b.GetUpperBound())})
+				p.pending[len(p.pending)-1].Exemplar = protobufExemplar(b.GetExemplar())
+
+				hasInf = hasInf || math.IsInf(b.GetUpperBound(), 1)
+			}
+
+			// the +Inf bucket is implicit in the protobuf format.
+			if len(h.GetBucket()) > 0 && !hasInf {
+				add(name+bucketSuffix, count, Label{Name: "le", Value: "+Inf"})
+			}
+
+			sumName, countName := name+sumSuffix, name+countSuffix
+			if mf.GetType() == dto.MetricType_GAUGE_HISTOGRAM {
+				sumName, countName = name+gsumSuffix, name+gcountSuffix
+			}
+
+			add(sumName, h.GetSampleSum())
+			add(countName, count)
+		}
+	}
+}
+
+// isNativeHistogram reports whether the message carries a native histogram,
+// which may be exposed along with the classic buckets.
+func isNativeHistogram(h *dto.Histogram) bool {
+	return len(h.GetPositiveSpan()) > 0 ||
+		len(h.GetNegativeSpan()) > 0 ||
+		h.GetZeroThreshold() > 0 ||
+		h.GetZeroCount() > 0 ||
+		h.GetZeroCountFloat() > 0
+}
+
+func (p *ProtobufParser) addNativeHistogram(name string, labels []Label, ts int64, h *dto.Histogram) {
+	sorted := append([]Label(nil), labels...)
+	SortLabels(sorted)
+
+	nh := NativeHistogram{
+		Name:          name,
+		Labels:        sorted,
+		Schema:        h.GetSchema(),
+		ZeroThreshold: h.GetZeroThreshold(),
+		ZeroCount:     float64(h.GetZeroCount()),
+		Count:         float64(h.GetSampleCount()),
+		Sum:           h.GetSampleSum(),
+		PositiveSpans: protobufSpans(h.GetPositiveSpan()),
+		NegativeSpans: protobufSpans(h.GetNegativeSpan()),
+		Timestamp:     ts,
+	}
+
+	// float histograms carry absolute counts, integer ones are delta encoded.
+	if h.SampleCountFloat != nil {
+		nh.Count = h.GetSampleCountFloat()
+		nh.ZeroCount = h.GetZeroCountFloat()
+		nh.PositiveBuckets = h.GetPositiveCount()
+		nh.NegativeBuckets = h.GetNegativeCount()
+	} else {
+		nh.PositiveBuckets = DeltasToCounts(h.GetPositiveDelta())
+		nh.NegativeBuckets = DeltasToCounts(h.GetNegativeDelta())
+	}
+
+	mk := MetricKey{
+		Name:   name,
+		Labels: sorted,
+	}
+	p.natives[mk.String()] = nh
+}
+
+func protobufSpans(spans []*dto.BucketSpan) []BucketSpan {
+	out := make([]BucketSpan, len(spans))
+	for i, s := range spans {
+		out[i] = BucketSpan{
+			Offset: s.GetOffset(),
+			Length: s.GetLength(),
+		}
+	}
+	return out
+}
+
+func protobufMetricType(t dto.MetricType) MetricType {
+	switch t {
+	case dto.MetricType_COUNTER:
+		return TypeCounter
+	case dto.MetricType_GAUGE:
+		return TypeGauge
+	case dto.MetricType_SUMMARY:
+		return TypeSummary
+	case dto.MetricType_HISTOGRAM:
+		return TypeHistogram
+	case dto.MetricType_GAUGE_HISTOGRAM:
+		return TypeGaugeHistogram
+	}
+	return TypeUnknown
+}
+
+func protobufLabels(pairs []*dto.LabelPair) []Label {
+	if len(pairs) == 0 {
+		return nil
+	}
+
+	labels := make([]Label, len(pairs))
+	for i, lp := range pairs {
+		labels[i] = Label{
+			Name:  lp.GetName(),
+			Value: lp.GetValue(),
+		}
+	}
+	return labels
+}
+
+func protobufExemplar(e *dto.Exemplar) *Exemplar {
+	if e == nil {
+		return nil
+	}
+
+	var ts int64
+	if e.GetTimestamp() != nil {
+		ts = e.GetTimestamp().AsTime().UnixMilli()
+	}
+
+	return &Exemplar{
+		Labels:    protobufLabels(e.GetLabel()),
+		Value:     e.GetValue(),
+		Timestamp: ts,
+	}
+}
+
+// FormatFloat formats a float the way it appears in label values such as "le" and "quantile".
+func FormatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,174 @@
+package metric
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestProtobufParser(t *testing.T) {
+	families := []*dto.MetricFamily{
+		{
+			Name: proto.String("http_requests_total"),
+			Help: proto.String("Total requests."),
+			Type: dto.MetricType_COUNTER.Enum(),
+			Metric: []*dto.Metric{
+				{
+					Label:       []*dto.LabelPair{{Name: proto.String("code"), Value: proto.String("200")}},
+					Counter:     &dto.Counter{Value: proto.Float64(10)},
+					TimestampMs: proto.Int64(1712000000000),
+				},
+			},
+		},
+		{
+			Name: proto.String("rpc_duration_seconds"),
+			Type: dto.MetricType_HISTOGRAM.Enum(),
+			Metric: []*dto.Metric{
+				{
+					Histogram: &dto.Histogram{
+						SampleCount: proto.Uint64(5),
+						SampleSum:   proto.Float64(1.5),
+						Bucket: []*dto.Bucket{
+							{UpperBound: proto.Float64(0.005), CumulativeCount: proto.Uint64(2)},
+							{UpperBound: proto.Float64(1), CumulativeCount: proto.Uint64(4)},
+						},
+					},
+				},
+			},
+		},
+		{
+			Name: proto.String("gc_duration_seconds"),
+			Type: dto.MetricType_SUMMARY.Enum(),
+			Metric: []*dto.Metric{
+				{
+					Summary: &dto.Summary{
+						SampleCount: proto.Uint64(3),
+						SampleSum:   proto.Float64(0.3),
+						Quantile:    []*dto.Quantile{{Quantile: proto.Float64(0.99), Value: proto.Float64(0.2)}},
+					},
+				},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	for _, mf := range families {
+		_, err := protodelim.MarshalTo(&buf, mf)
+		require.NoError(t, err)
+	}
+
+	p := NewParser("application/vnd.google.protobuf; proto=io.prometheus.client.MetricFamily; encoding=delimited", &buf)
+	require.IsType(t, &ProtobufParser{}, p)
+
+	var metrics []RawMetric
+	for {
+		m, err := p.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+
+		metrics = append(metrics, m)
+	}
+
+	require.Len(t, metrics, 9)
+	require.Equal(t, RawMetric{
+		Name:      "http_requests_total",
+		Labels:    []Label{{Name: "code", Value: "200"}},
+		Value:     10,
+		Timestamp: 1712000000000,
+	}, metrics[0])
+	require.Equal(t, RawMetric{
+		Name:   "gc_duration_seconds",
+		Labels: []Label{{Name: "quantile", Value: "0.99"}},
+		Value:  0.2,
+	}, metrics[6])
+
+	require.Equal(t, Metadata{
+		Name: "http_requests_total",
+		Type: TypeCounter,
+		Help: "Total requests.",
+	}, p.Metadata()["http_requests_total"])
+
+	hists, _ := ParseHistogram(metrics, p.Metadata())
+	require.Equal(t, []Bin{
+		{Value: 0.005, Count: 2},
+		{Value: 1, Count: 4},
+		{Value: math.Inf(1), Count: 5},
+	}, hists["rpc_duration_seconds"].Bins)
+}
+
+func TestProtobufParserTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	_, err := protodelim.MarshalTo(&buf, &dto.MetricFamily{Name: proto.String("up")})
+	require.NoError(t, err)
+
+	p := NewProtobufParser(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+
+	_, err = p.Next()
+	require.ErrorIs(t, err, ErrInvalidProtobuf)
+}
+
+func TestProtobufParserNativeHistogram(t *testing.T) {
+	mf := &dto.MetricFamily{
+		Name: proto.String("request_duration_seconds"),
+		Type: dto.MetricType_HISTOGRAM.Enum(),
+		Metric: []*dto.Metric{
+			{
+				Label: []*dto.LabelPair{{Name: proto.String("path"), Value: proto.String("/")}},
+				Histogram: &dto.Histogram{
+					SampleCount:   proto.Uint64(6),
+					SampleSum:     proto.Float64(3.2),
+					Schema:        proto.Int32(1),
+					ZeroThreshold: proto.Float64(0.001),
+					ZeroCount:     proto.Uint64(1),
+					PositiveSpan:  []*dto.BucketSpan{{Offset: proto.Int32(-1), Length: proto.Uint32(2)}},
+					PositiveDelta: []int64{2, 1},
+				},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	_, err := protodelim.MarshalTo(&buf, mf)
+	require.NoError(t, err)
+
+	p := NewProtobufParser(&buf)
+
+	var metrics []RawMetric
+	for {
+		m, err := p.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+
+		metrics = append(metrics, m)
+	}
+
+	// sum and count are still exposed as series.
+	require.Len(t, metrics, 2)
+
+	require.Equal(t, map[string]NativeHistogram{
+		`request_duration_seconds{path="/"}`: {
+			Name:            "request_duration_seconds",
+			Labels:          []Label{{Name: "path", Value: "/"}},
+			Schema:          1,
+			ZeroThreshold:   0.001,
+			ZeroCount:       1,
+			Count:           6,
+			Sum:             3.2,
+			PositiveSpans:   []BucketSpan{{Offset: -1, Length: 2}},
+			PositiveBuckets: []float64{2, 3},
+			NegativeSpans:   []BucketSpan{},
+			NegativeBuckets: []float64{},
+		},
+	}, p.NativeHistograms())
+}
@@ -0,0 +1,96 @@
+package metric
+
+import (
+	"math"
+	"sort"
+)
+
+// Bucket is a cumulative bucket of a histogram, counting the observations
+// less than or equal to its upper bound.
+type Bucket struct {
+	UpperBound float64
+	Count      float64
+}
+
+// BucketQuantile estimates a quantile from cumulative buckets, assuming that observations
+// are uniformly distributed within each bucket, as Prometheus does.
+func BucketQuantile(q float64, buckets []Bucket) float64 {
+	switch {
+	case math.IsNaN(q):
+		return math.NaN()
+	case q < 0:
+		return math.Inf(-1)
+	case q > 1:
+		return math.Inf(1)
+	}
+
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].UpperBound < buckets[j].UpperBound
+	})
+
+	if len(buckets) < 2 || !math.IsInf(buckets[len(buckets)-1].UpperBound, 1) {
+		return math.NaN()
+	}
+
+	// counts may be slightly non monotonic, because of precision loss.
+	for i := 1; i < len(buckets); i++ {
+		buckets[i].Count = max(buckets[i].Count, buckets[i-1].Count)
+	}
+
+	observations := buckets[len(buckets)-1].Count
+	if observations == 0 {
+		return math.NaN()
+	}
+
+	rank := q * observations
+
+	b := sort.Search(len(buckets)-1, func(i int) bool {
+		return buckets[i].Count >= rank
+	})
+
+	switch {
+	case b == len(buckets)-1:
+		return buckets[len(buckets)-2].UpperBound
+	case b == 0 && buckets[0].UpperBound <= 0:
+		return buckets[0].UpperBound
+	}
+
+	bucketStart := 0.0
+	bucketEnd := buckets[b].UpperBound
+	count := buckets[b].Count
+	if b > 0 {
+		bucketStart = buckets[b-1].UpperBound
+		count -= buckets[b-1].Count
+		rank -= buckets[b-1].Count
+	}
+	return bucketStart + (bucketEnd-bucketStart)*(rank/count)
+}
+
+// BinsQuantile estimates a quantile from the bins of a classic histogram.
+func BinsQuantile(q float64, bins []Bin) float64 {
+	buckets := make([]Bucket, len(bins))
+	for i, b := range bins {
+		buckets[i] = Bucket{UpperBound: b.Value, Count: float64(b.Count)}
+	}
+	return BucketQuantile(q, buckets)
+}
+
+// QuantileSamples estimates a quantile from the observations made between each pair of
+// consecutive snapshots of a histogram, which must be sorted by timestamp. The quantile
+// is NaN for intervals without observations.
+func QuantileSamples(q float64, snapshots []Histogram) []Sample {
+	if len(snapshots) < 2 {
+		return nil
+	}
+
+	samples := make([]Sample, 0, len(snapshots)-1)
+	for i := 1; i < len(snapshots); i++ {
+		prev, curr := snapshots[i-1], snapshots[i]
+
+		samples = append(samples, Sample{
+			Timestamp: curr.Timestamp,
+			Value:     BinsQuantile(q, DeltaBins(prev.Bins, curr.Bins)),
+		})
+	}
+	return samples
+}
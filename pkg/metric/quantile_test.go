@@ -0,0 +1,57 @@
+package metric
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketQuantile(t *testing.T) {
+	buckets := func() []Bucket {
+		return []Bucket{
+			{UpperBound: math.Inf(1), Count: 10},
+			{UpperBound: 0.1, Count: 4},
+			{UpperBound: 1, Count: 8},
+		}
+	}
+
+	require.InDelta(t, 0.05, BucketQuantile(0.2, buckets()), 1e-9)
+	require.InDelta(t, 0.55, BucketQuantile(0.6, buckets()), 1e-9)
+
+	// quantiles falling in the +Inf bucket are capped to the highest finite bound.
+	require.Equal(t, 1.0, BucketQuantile(0.99, buckets()))
+
+	require.True(t, math.IsInf(BucketQuantile(-1, buckets()), -1))
+	require.True(t, math.IsInf(BucketQuantile(2, buckets()), 1))
+	require.True(t, math.IsNaN(BucketQuantile(0.5, []Bucket{{UpperBound: 1, Count: 3}})))
+}
+
+func TestQuantileSamples(t *testing.T) {
+	snapshot := func(ts int64, counts ...uint64) Histogram {
+		bounds := []float64{0.1, 1, math.Inf(1)}
+
+		bins := make([]Bin, len(counts))
+		for i, c := range counts {
+			bins[i] = Bin{Value: bounds[i], Count: c}
+		}
+		return Histogram{Bins: bins, Timestamp: ts}
+	}
+
+	samples := QuantileSamples(0.5, []Histogram{
+		snapshot(1000, 0, 0, 0),
+		snapshot(2000, 10, 10, 10),
+		snapshot(3000, 10, 20, 20),
+		snapshot(4000, 10, 20, 20),
+	})
+
+	require.Len(t, samples, 3)
+	require.Equal(t, []int64{2000, 3000, 4000}, []int64{samples[0].Timestamp, samples[1].Timestamp, samples[2].Timestamp})
+	require.InDelta(t, 0.05, samples[0].Value, 1e-9)
+	require.InDelta(t, 0.55, samples[1].Value, 1e-9)
+
+	// no observations were made in the last interval.
+	require.True(t, math.IsNaN(samples[2].Value))
+
+	require.Nil(t, QuantileSamples(0.5, []Histogram{snapshot(1000, 1, 2, 3)}))
+}
@@ -0,0 +1,25 @@
+package metric
+
+// Rate converts the samples of a counter into the per-second rate between consecutive samples,
+// timestamped as the later sample. A decrease is a counter reset, after which the counter restarted from zero.
+func Rate(samples []Sample) []Sample {
+	if len(samples) < 2 {
+		return nil
+	}
+
+	rates := make([]Sample, 0, len(samples)-1)
+	for i := 1; i < len(samples); i++ {
+		prev, curr := samples[i-1], samples[i]
+
+		increase := curr.Value - prev.Value
+		if curr.Value < prev.Value {
+			increase = curr.Value
+		}
+
+		rates = append(rates, Sample{
+			Timestamp: curr.Timestamp,
+			Value:     increase / (float64(curr.Timestamp-prev.Timestamp) / 1000),
+		})
+	}
+	return rates
+}
@@ -0,0 +1,24 @@
+package metric
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRate(t *testing.T) {
+	samples := []Sample{
+		{Timestamp: 1000, Value: 10},
+		{Timestamp: 2000, Value: 20},
+		{Timestamp: 4000, Value: 30},
+		{Timestamp: 5000, Value: 4},
+	}
+
+	require.Equal(t, []Sample{
+		{Timestamp: 2000, Value: 10},
+		{Timestamp: 4000, Value: 5},
+		{Timestamp: 5000, Value: 4},
+	}, Rate(samples))
+
+	require.Nil(t, Rate(samples[:1]))
+}
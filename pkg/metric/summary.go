@@ -0,0 +1,164 @@
+package metric
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const quantileLabel = "quantile"
+
+type Quantile struct {
+	Quantile float64
+	Value    float64
+}
+
+type Summary struct {
+	Name      string
+	Labels    []Label
+	Quantiles []Quantile
+	Sum       float64
+	Count     float64
+	Timestamp int64
+}
+
+// QuantileKey returns the key of the series holding the i-th quantile of the summary.
+func (s *Summary) QuantileKey(i int) MetricKey {
+	labels := append(s.Labels[:len(s.Labels):len(s.Labels)], Label{
+		Name:  quantileLabel,
+		Value: FormatFloat(s.Quantiles[i].Quantile),
+	})
+	SortLabels(labels)
+
+	return MetricKey{
+		Name:   s.Name,
+		Labels: labels,
+	}
+}
+
+// Series returns the quantile, _sum and _count series of the summary.
+func (s *Summary) Series() []RawMetric {
+	series := make([]RawMetric, 0, len(s.Quantiles)+2)
+	for i, q := range s.Quantiles {
+		key := s.QuantileKey(i)
+		series = append(series, RawMetric{Name: key.Name, Labels: key.Labels, Value: q.Value, Timestamp: s.Timestamp})
+	}
+
+	return append(series,
+		RawMetric{Name: s.Name + sumSuffix, Labels: s.Labels, Value: s.Sum, Timestamp: s.Timestamp},
+		RawMetric{Name: s.Name + countSuffix, Labels: s.Labels, Value: s.Count, Timestamp: s.Timestamp},
+	)
+}
+
+// ParseSummary groups the quantile series of each summary with its _sum and _count series.
+// As for histograms, declared types take precedence over guessing by series name.
+func ParseSummary(metrics []RawMetric, metadata map[string]Metadata) (map[string]Summary, []RawMetric) {
+	type summaryMetrics struct {
+		key       MetricKey
+		quantiles []RawMetric
+		count     *RawMetric
+		sum       *RawMetric
+	}
+
+	filteredMetrics := make([]RawMetric, 0, len(metrics))
+
+	summaries := make(map[string]summaryMetrics)
+	for _, m := range metrics {
+		name := m.Name
+		switch {
+		case m.Find(quantileLabel) != "":
+		case strings.HasSuffix(name, countSuffix):
+			name = strings.TrimSuffix(name, countSuffix)
+		case strings.HasSuffix(name, sumSuffix):
+			name = strings.TrimSuffix(name, sumSuffix)
+		default:
+			filteredMetrics = append(filteredMetrics, m)
+			continue
+		}
+
+		if !isSummarySeries(m.Name, name, metadata) {
+			filteredMetrics = append(filteredMetrics, m)
+			continue
+		}
+
+		labels, _ := m.Remove(quantileLabel)
+		SortLabels(labels)
+
+		mk := MetricKey{
+			Name:   name,
+			Labels: labels,
+		}
+
+		s := mk.String()
+		sm := summaries[s]
+		sm.key = mk
+
+		switch {
+		case name == m.Name:
+			sm.quantiles = append(sm.quantiles, m)
+		case strings.HasSuffix(m.Name, countSuffix):
+			sm.count = &m
+		default:
+			sm.sum = &m
+		}
+		summaries[s] = sm
+	}
+
+	out := make(map[string]Summary, len(summaries))
+	for k, sm := range summaries {
+		quantiles, err := parseQuantiles(sm.quantiles)
+		if err != nil || len(quantiles) == 0 || sm.count == nil || sm.sum == nil {
+			if sm.count != nil {
+				filteredMetrics = append(filteredMetrics, *sm.count)
+			}
+
+			if sm.sum != nil {
+				filteredMetrics = append(filteredMetrics, *sm.sum)
+			}
+
+			filteredMetrics = append(filteredMetrics, sm.quantiles...)
+			continue
+		}
+
+		out[k] = Summary{
+			Name:      sm.key.Name,
+			Labels:    sm.key.Labels,
+			Quantiles: quantiles,
+			Sum:       sm.sum.Value,
+			Count:     sm.count.Value,
+			Timestamp: sm.count.Timestamp,
+		}
+	}
+	return out, filteredMetrics
+}
+
+func isSummarySeries(name, family string, metadata map[string]Metadata) bool {
+	if md, ok := metadata[family]; ok {
+		return md.Type == TypeSummary
+	}
+
+	if _, ok := metadata[name]; ok {
+		return false
+	}
+	return true
+}
+
+func parseQuantiles(metrics []RawMetric) ([]Quantile, error) {
+	quantiles := make([]Quantile, len(metrics))
+	for i, m := range metrics {
+		q, err := strconv.ParseFloat(m.Find(quantileLabel), 64)
+		if err != nil {
+			return nil, err
+		}
+
+		quantiles[i] = Quantile{
+			Quantile: q,
+			Value:    m.Value,
+		}
+	}
+
+	sort.Slice(quantiles, func(i, j int) bool {
+		return quantiles[i].Quantile < quantiles[j].Quantile
+	})
+	return quantiles, nil
+}
@@ -0,0 +1,49 @@
+package metric
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSummary(t *testing.T) {
+	metrics := []RawMetric{
+		{Name: "rpc_seconds", Labels: []Label{{Name: "service", Value: "a"}, {Name: "quantile", Value: "0.99"}}, Value: 0.3},
+		{Name: "rpc_seconds", Labels: []Label{{Name: "service", Value: "a"}, {Name: "quantile", Value: "0.5"}}, Value: 0.1},
+		{Name: "rpc_seconds_sum", Labels: []Label{{Name: "service", Value: "a"}}, Value: 12},
+		{Name: "rpc_seconds_count", Labels: []Label{{Name: "service", Value: "a"}}, Value: 100},
+		{Name: "jobs_count", Value: 7},
+	}
+
+	t.Run("by series name", func(t *testing.T) {
+		summaries, rem := ParseSummary(metrics, nil)
+
+		require.Equal(t, map[string]Summary{
+			`rpc_seconds{service="a"}`: {
+				Name:   "rpc_seconds",
+				Labels: []Label{{Name: "service", Value: "a"}},
+				Quantiles: []Quantile{
+					{Quantile: 0.5, Value: 0.1},
+					{Quantile: 0.99, Value: 0.3},
+				},
+				Sum:   12,
+				Count: 100,
+			},
+		}, summaries)
+		require.Equal(t, []RawMetric{{Name: "jobs_count", Value: 7}}, rem)
+
+		s := summaries[`rpc_seconds{service="a"}`]
+		key := s.QuantileKey(1)
+		require.Equal(t, `rpc_seconds{quantile="0.99", service="a"}`, key.String())
+	})
+
+	t.Run("by type", func(t *testing.T) {
+		metadata := map[string]Metadata{
+			"rpc_seconds": {Name: "rpc_seconds", Type: TypeGauge},
+		}
+
+		summaries, rem := ParseSummary(metrics, metadata)
+		require.Empty(t, summaries)
+		require.Len(t, rem, len(metrics))
+	})
+}
@@ -0,0 +1,265 @@
+// Package push implements the push API of the Prometheus Pushgateway, so that
+// short-lived jobs can push their metrics to proq.
+package push
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ostafen/proq/pkg/metric"
+	"github.com/ostafen/proq/pkg/scrape"
+)
+
+// PathPrefix is the path the grouping key of a push is appended to.
+const PathPrefix = "/metrics/job/"
+
+// PushTimeMetric records the time of the last push of each group.
+const PushTimeMetric = "push_time_seconds"
+
+const base64Suffix = "@base64"
+
+var ErrInvalidGroupingKey = errors.New("invalid grouping key")
+
+// group holds the metrics last pushed with a grouping key.
+type group struct {
+	labels   []metric.Label
+	metrics  []metric.RawMetric
+	metadata map[string]metric.Metadata
+	pushTime time.Time
+}
+
+// Gateway keeps the metrics pushed by jobs, grouped by the labels in the push path.
+// It is safe for concurrent use.
+type Gateway struct {
+	mu     sync.Mutex
+	groups map[string]*group
+}
+
+func NewGateway() *Gateway {
+	return &Gateway{groups: make(map[string]*group)}
+}
+
+// ServeHTTP handles requests to /metrics/job/<job>{/<label>/<value>}:
+//   - PUT replaces all the metrics of the group;
+//   - POST replaces the metric families of the group which are pushed again;
+//   - DELETE removes the group.
+func (gw *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	labels, err := parseGroupingKey(strings.TrimPrefix(r.URL.EscapedPath(), PathPrefix))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut, http.MethodPost:
+		metrics, metadata, err := parsePush(r, labels)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		gw.push(labels, metrics, metadata, r.Method == http.MethodPut)
+		w.WriteHeader(http.StatusOK)
+	case http.MethodDelete:
+		gw.delete(labels)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		w.Header().Set("Allow", "PUT, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// parseGroupingKey parses the "<job>{/<label>/<value>}" part of the path. Values
+// can be encoded in base64, to contain slashes, by suffixing the label name with "@base64".
+func parseGroupingKey(path string) ([]metric.Label, error) {
+	segments := strings.Split(strings.TrimSuffix(path, "/"), "/")
+	segments = append([]string{scrape.JobLabel}, segments...)
+
+	if len(segments)%2 != 0 {
+		return nil, fmt.Errorf("%w: missing value of label \"%s\"", ErrInvalidGroupingKey, segments[len(segments)-1])
+	}
+
+	labels := make([]metric.Label, 0, len(segments)/2)
+	for i := 0; i < len(segments); i += 2 {
+		l, err := parseGroupingLabel(segments[i], segments[i+1])
+		if err != nil {
+			return nil, err
+		}
+
+		if slices.ContainsFunc(labels, func(other metric.Label) bool { return other.Name == l.Name }) {
+			return nil, fmt.Errorf("%w: duplicate label \"%s\"", ErrInvalidGroupingKey, l.Name)
+		}
+		labels = append(labels, l)
+	}
+
+	if labels[0].Value == "" {
+		return nil, fmt.Errorf("%w: empty job name", ErrInvalidGroupingKey)
+	}
+
+	metric.SortLabels(labels)
+	return labels, nil
+}
+
+func parseGroupingLabel(name, value string) (metric.Label, error) {
+	name, encoded := strings.CutSuffix(name, base64Suffix)
+	if !isLabelName(name) || strings.HasPrefix(name, "__") {
+		return metric.Label{}, fmt.Errorf("%w: invalid label name \"%s\"", ErrInvalidGroupingKey, name)
+	}
+
+	if encoded {
+		b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+		if err != nil {
+			return metric.Label{}, fmt.Errorf("%w: invalid base64 value of label \"%s\"", ErrInvalidGroupingKey, name)
+		}
+		return metric.Label{Name: name, Value: string(b)}, nil
+	}
+
+	value, err := url.PathUnescape(value)
+	if err != nil {
+		return metric.Label{}, fmt.Errorf("%w: %w", ErrInvalidGroupingKey, err)
+	}
+	return metric.Label{Name: name, Value: value}, nil
+}
+
+func isLabelName(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for i, c := range s {
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// parsePush reads the pushed metrics, and adds the grouping labels to them.
+// As for the Pushgateway, samples must not carry a timestamp.
+func parsePush(r *http.Request, groupingLabels []metric.Label) ([]metric.RawMetric, map[string]metric.Metadata, error) {
+	var metrics []metric.RawMetric
+
+	p := metric.NewParser(r.Header.Get("Content-Type"), r.Body)
+	for {
+		m, err := p.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if m.Timestamp != 0 {
+			return nil, nil, fmt.Errorf("pushed metric %s has a timestamp", m.Name)
+		}
+
+		m.Labels = mergeLabels(m.Labels, groupingLabels)
+		metrics = append(metrics, m)
+	}
+	return metrics, p.Metadata(), nil
+}
+
+// mergeLabels adds the grouping labels to those of a series, which take precedence on conflict.
+func mergeLabels(labels, groupingLabels []metric.Label) []metric.Label {
+	out := make([]metric.Label, 0, len(labels)+len(groupingLabels))
+	for _, l := range labels {
+		if !slices.ContainsFunc(groupingLabels, func(gl metric.Label) bool { return gl.Name == l.Name }) {
+			out = append(out, l)
+		}
+	}
+
+	out = append(out, groupingLabels...)
+	metric.SortLabels(out)
+	return out
+}
+
+func (gw *Gateway) push(labels []metric.Label, metrics []metric.RawMetric, metadata map[string]metric.Metadata, replace bool) {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+
+	key := groupKey(labels)
+
+	g, ok := gw.groups[key]
+	if !ok || replace {
+		g = &group{labels: labels, metadata: make(map[string]metric.Metadata)}
+		gw.groups[key] = g
+	}
+
+	// only the families which are pushed again are replaced.
+	pushed := make(map[string]bool)
+	for _, m := range metrics {
+		pushed[familyName(m.Name, metadata)] = true
+	}
+
+	g.metrics = slices.DeleteFunc(g.metrics, func(m metric.RawMetric) bool {
+		return pushed[familyName(m.Name, g.metadata)]
+	})
+	g.metrics = append(g.metrics, metrics...)
+
+	maps.Copy(g.metadata, metadata)
+	g.pushTime = time.Now()
+}
+
+func (gw *Gateway) delete(labels []metric.Label) {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+
+	delete(gw.groups, groupKey(labels))
+}
+
+func groupKey(labels []metric.Label) string {
+	mk := metric.MetricKey{Labels: labels}
+	return mk.String()
+}
+
+func familyName(name string, metadata map[string]metric.Metadata) string {
+	if md, ok := metric.LookupMetadata(metadata, name); ok {
+		return md.Name
+	}
+	return name
+}
+
+// Result returns the metrics currently held by the gateway as if they were scraped at ts,
+// along with the push_time_seconds series of each group.
+func (gw *Gateway) Result(ts time.Time) *scrape.Result {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+
+	metadata := map[string]metric.Metadata{
+		PushTimeMetric: {
+			Name: PushTimeMetric,
+			Type: metric.TypeGauge,
+			Help: "Last Unix time when this group was changed in the Pushgateway.",
+		},
+	}
+
+	var metrics []metric.RawMetric
+	for _, k := range slices.Sorted(maps.Keys(gw.groups)) {
+		g := gw.groups[k]
+
+		for _, m := range g.metrics {
+			m.Labels = slices.Clone(m.Labels)
+			m.Timestamp = ts.UnixMilli()
+			metrics = append(metrics, m)
+		}
+
+		metrics = append(metrics, metric.RawMetric{
+			Name:      PushTimeMetric,
+			Labels:    slices.Clone(g.labels),
+			Value:     float64(g.pushTime.UnixMilli()) / 1000,
+			Timestamp: ts.UnixMilli(),
+		})
+
+		maps.Copy(metadata, g.metadata)
+	}
+	return scrape.NewResult(scrape.Target{}, metrics, metadata, ts)
+}
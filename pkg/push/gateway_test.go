@@ -0,0 +1,101 @@
+package push
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ostafen/proq/pkg/metric"
+)
+
+func push(t *testing.T, gw *Gateway, method, path, body string) int {
+	rec := httptest.NewRecorder()
+	gw.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return rec.Code
+}
+
+func TestPush(t *testing.T) {
+	gw := NewGateway()
+
+	code := push(t, gw, http.MethodPut, "/metrics/job/backup/instance/db1", `# TYPE backup_duration_seconds gauge
+backup_duration_seconds 12.5
+# TYPE backup_size_bytes gauge
+backup_size_bytes{instance="other",kind="full"} 1024
+`)
+	require.Equal(t, http.StatusOK, code)
+
+	ts := time.UnixMilli(5000)
+	res := gw.Result(ts)
+
+	grouping := []metric.Label{{Name: "instance", Value: "db1"}, {Name: "job", Value: "backup"}}
+	require.Equal(t, []metric.RawMetric{
+		{Name: "backup_duration_seconds", Labels: grouping, Value: 12.5, Timestamp: 5000},
+		{Name: "backup_size_bytes", Labels: []metric.Label{grouping[0], grouping[1], {Name: "kind", Value: "full"}}, Value: 1024, Timestamp: 5000},
+	}, res.Metrics[:2])
+
+	require.Equal(t, PushTimeMetric, res.Metrics[2].Name)
+	require.Equal(t, grouping, res.Metrics[2].Labels)
+	require.Equal(t, metric.TypeGauge, res.Metadata["backup_size_bytes"].Type)
+
+	// POST only replaces the families which are pushed again.
+	code = push(t, gw, http.MethodPost, "/metrics/job/backup/instance/db1", "backup_duration_seconds 3\n")
+	require.Equal(t, http.StatusOK, code)
+
+	res = gw.Result(ts)
+	require.Len(t, res.Metrics, 3)
+	require.Equal(t, 1024.0, res.Metrics[0].Value)
+	require.Equal(t, 3.0, res.Metrics[1].Value)
+
+	// PUT replaces the whole group.
+	code = push(t, gw, http.MethodPut, "/metrics/job/backup/instance/db1", "backup_duration_seconds 4\n")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, gw.Result(ts).Metrics, 2)
+
+	code = push(t, gw, http.MethodDelete, "/metrics/job/backup/instance/db1", "")
+	require.Equal(t, http.StatusAccepted, code)
+	require.Empty(t, gw.Result(ts).Metrics)
+}
+
+func TestPushHistogram(t *testing.T) {
+	gw := NewGateway()
+
+	code := push(t, gw, http.MethodPut, "/metrics/job/batch", `# TYPE job_duration_seconds histogram
+job_duration_seconds_bucket{le="1"} 1
+job_duration_seconds_bucket{le="+Inf"} 2
+job_duration_seconds_sum 3
+job_duration_seconds_count 2
+`)
+	require.Equal(t, http.StatusOK, code)
+
+	res := gw.Result(time.UnixMilli(1000))
+	require.Len(t, res.Histograms, 1)
+	require.Len(t, res.Metrics, 1)
+}
+
+func TestInvalidPush(t *testing.T) {
+	gw := NewGateway()
+
+	require.Equal(t, http.StatusBadRequest, push(t, gw, http.MethodPut, "/metrics/job/", ""))
+	require.Equal(t, http.StatusBadRequest, push(t, gw, http.MethodPut, "/metrics/job/batch/instance", ""))
+	require.Equal(t, http.StatusBadRequest, push(t, gw, http.MethodPut, "/metrics/job/batch/__name__/x", ""))
+	require.Equal(t, http.StatusBadRequest, push(t, gw, http.MethodPut, "/metrics/job/batch", "up 1 1000\n"))
+	require.Equal(t, http.StatusBadRequest, push(t, gw, http.MethodPut, "/metrics/job/batch", "up{ 1\n"))
+	require.Equal(t, http.StatusMethodNotAllowed, push(t, gw, http.MethodGet, "/metrics/job/batch", ""))
+}
+
+func TestParseGroupingKey(t *testing.T) {
+	labels, err := parseGroupingKey("batch/path@base64/L3Zhci90bXA/host/a%20b")
+	require.NoError(t, err)
+	require.Equal(t, []metric.Label{
+		{Name: "host", Value: "a b"},
+		{Name: "job", Value: "batch"},
+		{Name: "path", Value: "/var/tmp"},
+	}, labels)
+
+	_, err = parseGroupingKey("batch/job/other")
+	require.ErrorIs(t, err, ErrInvalidGroupingKey)
+}
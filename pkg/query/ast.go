@@ -0,0 +1,146 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/ostafen/proq/pkg/metric"
+)
+
+// Expr is a node of a parsed query.
+type Expr interface {
+	expr()
+}
+
+// NumberLiteral is a scalar constant, such as 0.99.
+type NumberLiteral struct {
+	Val float64
+}
+
+// VectorSelector selects the latest sample of each series matching its matchers.
+type VectorSelector struct {
+	Name     string
+	Matchers []*Matcher
+}
+
+// MatrixSelector selects the samples of each matching series which fall in the range.
+type MatrixSelector struct {
+	Vector *VectorSelector
+	Range  time.Duration
+}
+
+// Call is the invocation of one of the supported functions.
+type Call struct {
+	Func string
+	Args []Expr
+}
+
+// AggregateExpr aggregates the series of a vector, grouped by the given labels.
+type AggregateExpr struct {
+	Op       string
+	Grouping []string
+	Expr     Expr
+}
+
+// BinaryExpr is an arithmetic operation between scalars and vectors.
+type BinaryExpr struct {
+	Op  byte
+	LHS Expr
+	RHS Expr
+}
+
+func (*NumberLiteral) expr()  {}
+func (*VectorSelector) expr() {}
+func (*MatrixSelector) expr() {}
+func (*Call) expr()           {}
+func (*AggregateExpr) expr()  {}
+func (*BinaryExpr) expr()     {}
+
+// IsScalar reports whether the expression evaluates to a scalar, rather than to a vector.
+func IsScalar(e Expr) bool {
+	switch e := e.(type) {
+	case *NumberLiteral:
+		return true
+	case *BinaryExpr:
+		return IsScalar(e.LHS) && IsScalar(e.RHS)
+	}
+	return false
+}
+
+type MatchType string
+
+const (
+	MatchEqual     MatchType = "="
+	MatchNotEqual  MatchType = "!="
+	MatchRegexp    MatchType = "=~"
+	MatchNotRegexp MatchType = "!~"
+)
+
+// Matcher filters series by the value of a label. A missing label has an empty value.
+type Matcher struct {
+	Name  string
+	Value string
+	Type  MatchType
+
+	re *regexp.Regexp
+}
+
+func NewMatcher(t MatchType, name, value string) (*Matcher, error) {
+	m := &Matcher{
+		Name:  name,
+		Value: value,
+		Type:  t,
+	}
+
+	if t == MatchRegexp || t == MatchNotRegexp {
+		// as in Prometheus, regular expressions are fully anchored.
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression \"%s\": %w", value, err)
+		}
+		m.re = re
+	}
+	return m, nil
+}
+
+func (m *Matcher) Matches(v string) bool {
+	switch m.Type {
+	case MatchEqual:
+		return v == m.Value
+	case MatchNotEqual:
+		return v != m.Value
+	case MatchRegexp:
+		return m.re.MatchString(v)
+	default:
+		return !m.re.MatchString(v)
+	}
+}
+
+// Matches reports whether the series identified by key is selected.
+func (vs *VectorSelector) Matches(key metric.MetricKey) bool {
+	if vs.Name != "" && key.Name != vs.Name {
+		return false
+	}
+
+	for _, m := range vs.Matchers {
+		v := key.Name
+		if m.Name != metricNameLabel {
+			v = labelValue(key.Labels, m.Name)
+		}
+
+		if !m.Matches(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func labelValue(labels []metric.Label, name string) string {
+	for _, l := range labels {
+		if l.Name == name {
+			return l.Value
+		}
+	}
+	return ""
+}
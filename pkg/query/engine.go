@@ -0,0 +1,391 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/ostafen/proq/pkg/metric"
+)
+
+// LookbackDelta is how far back an instant selector looks for the latest sample of a series.
+const LookbackDelta = 5 * time.Minute
+
+// Storage is the source of the series a query is evaluated against.
+type Storage interface {
+	Series(onSeries func(key metric.MetricKey))
+	Samples(key metric.MetricKey, onSample func(metric.Sample)) int
+}
+
+// Series is a series of the result of a query, with a sample for each evaluation step.
+type Series struct {
+	Metric  metric.MetricKey
+	Samples []metric.Sample
+}
+
+type scalar float64
+
+type vectorElement struct {
+	metric metric.MetricKey
+	value  float64
+}
+
+type vector []vectorElement
+
+type rangeSeries struct {
+	metric  metric.MetricKey
+	samples []metric.Sample
+}
+
+// matrix holds the samples of each series which fall in the (start, end] range.
+type matrix struct {
+	series []rangeSeries
+	start  int64
+	end    int64
+}
+
+// Eval evaluates the expression at each step between start and end, and returns the resulting series
+// sorted by their key. The result of a scalar expression is a single series with an empty key.
+func Eval(st Storage, e Expr, start, end time.Time, step time.Duration) ([]Series, error) {
+	// steps are taken in milliseconds, so a shorter step would never advance.
+	if step.Milliseconds() <= 0 {
+		return nil, fmt.Errorf("invalid evaluation step: %s", step)
+	}
+
+	ev := &evaluator{
+		st:     st,
+		series: make(map[*VectorSelector][]rangeSeries),
+	}
+
+	out := make(map[string]*Series)
+	add := func(mk metric.MetricKey, s metric.Sample) {
+		key := mk.String()
+
+		series, ok := out[key]
+		if !ok {
+			series = &Series{Metric: mk}
+			out[key] = series
+		}
+		series.Samples = append(series.Samples, s)
+	}
+
+	for ts := start.UnixMilli(); ts <= end.UnixMilli(); ts += step.Milliseconds() {
+		v, err := ev.eval(e, ts)
+		if err != nil {
+			return nil, err
+		}
+
+		switch v := v.(type) {
+		case scalar:
+			add(metric.MetricKey{}, metric.Sample{Timestamp: ts, Value: float64(v)})
+		case vector:
+			for _, el := range v {
+				add(el.metric, metric.Sample{Timestamp: ts, Value: el.value})
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(out))
+	for k := range out {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	result := make([]Series, len(keys))
+	for i, k := range keys {
+		result[i] = *out[k]
+	}
+	return result, nil
+}
+
+type evaluator struct {
+	st Storage
+
+	// series caches the series loaded for each selector across evaluation steps.
+	series map[*VectorSelector][]rangeSeries
+}
+
+func (ev *evaluator) eval(e Expr, ts int64) (any, error) {
+	switch e := e.(type) {
+	case *NumberLiteral:
+		return scalar(e.Val), nil
+	case *VectorSelector:
+		return ev.vector(e, ts), nil
+	case *MatrixSelector:
+		return nil, fmt.Errorf("range vector selectors can only be passed to functions")
+	case *Call:
+		return ev.call(e, ts)
+	case *AggregateExpr:
+		v, err := ev.eval(e.Expr, ts)
+		if err != nil {
+			return nil, err
+		}
+
+		vec, ok := v.(vector)
+		if !ok {
+			return nil, fmt.Errorf("%s: expected instant vector argument", e.Op)
+		}
+		return aggregate(e.Op, e.Grouping, vec), nil
+	case *BinaryExpr:
+		lhs, err := ev.eval(e.LHS, ts)
+		if err != nil {
+			return nil, err
+		}
+
+		rhs, err := ev.eval(e.RHS, ts)
+		if err != nil {
+			return nil, err
+		}
+		return binaryOp(e.Op, lhs, rhs), nil
+	}
+	return nil, fmt.Errorf("unsupported expression %T", e)
+}
+
+func (ev *evaluator) call(c *Call, ts int64) (any, error) {
+	fn := functions[c.Func]
+
+	args := make([]any, len(c.Args))
+	for i, arg := range c.Args {
+		if fn.args[i] == argMatrix {
+			args[i] = ev.matrix(arg.(*MatrixSelector), ts)
+			continue
+		}
+
+		v, err := ev.eval(arg, ts)
+		if err != nil {
+			return nil, err
+		}
+
+		switch v.(type) {
+		case scalar:
+			if fn.args[i] != argScalar {
+				return nil, fmt.Errorf("%s: expected instant vector as argument %d", c.Func, i+1)
+			}
+		case vector:
+			if fn.args[i] != argVector {
+				return nil, fmt.Errorf("%s: expected scalar as argument %d", c.Func, i+1)
+			}
+		}
+		args[i] = v
+	}
+	return fn.call(args), nil
+}
+
+// Selector selects series by their key, as a VectorSelector does.
+type Selector interface {
+	Matches(key metric.MetricKey) bool
+}
+
+// Select returns the stored samples of the selected series, sorted by key.
+func Select(st Storage, sel Selector) []Series {
+	var out []Series
+	st.Series(func(key metric.MetricKey) {
+		if !sel.Matches(key) {
+			return
+		}
+
+		s := Series{Metric: key}
+		st.Samples(key, func(sample metric.Sample) {
+			s.Samples = append(s.Samples, sample)
+		})
+		out = append(out, s)
+	})
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Metric.String() < out[j].Metric.String()
+	})
+	return out
+}
+
+func (ev *evaluator) selectSeries(vs *VectorSelector) []rangeSeries {
+	if series, ok := ev.series[vs]; ok {
+		return series
+	}
+
+	var series []rangeSeries
+	ev.st.Series(func(key metric.MetricKey) {
+		if !vs.Matches(key) {
+			return
+		}
+
+		rs := rangeSeries{metric: key}
+		ev.st.Samples(key, func(s metric.Sample) {
+			rs.samples = append(rs.samples, s)
+		})
+		series = append(series, rs)
+	})
+
+	ev.series[vs] = series
+	return series
+}
+
+func (ev *evaluator) vector(vs *VectorSelector, ts int64) vector {
+	var vec vector
+	for _, rs := range ev.selectSeries(vs) {
+		samples := samplesInRange(rs.samples, ts-LookbackDelta.Milliseconds(), ts)
+		if len(samples) == 0 {
+			continue
+		}
+
+		vec = append(vec, vectorElement{
+			metric: rs.metric,
+			value:  samples[len(samples)-1].Value,
+		})
+	}
+	return vec
+}
+
+func (ev *evaluator) matrix(ms *MatrixSelector, ts int64) matrix {
+	m := matrix{
+		start: ts - ms.Range.Milliseconds(),
+		end:   ts,
+	}
+
+	for _, rs := range ev.selectSeries(ms.Vector) {
+		samples := samplesInRange(rs.samples, m.start, m.end)
+		if len(samples) == 0 {
+			continue
+		}
+
+		m.series = append(m.series, rangeSeries{
+			metric:  rs.metric,
+			samples: samples,
+		})
+	}
+	return m
+}
+
+// samplesInRange returns the samples whose timestamp falls in the (start, end] range.
+func samplesInRange(samples []metric.Sample, start, end int64) []metric.Sample {
+	i := sort.Search(len(samples), func(i int) bool {
+		return samples[i].Timestamp > start
+	})
+
+	j := sort.Search(len(samples), func(i int) bool {
+		return samples[i].Timestamp > end
+	})
+	return samples[i:j]
+}
+
+func aggregate(op string, grouping []string, vec vector) vector {
+	type group struct {
+		metric metric.MetricKey
+		value  float64
+		count  int
+	}
+
+	var order []string
+	groups := make(map[string]*group)
+	for _, el := range vec {
+		labels := make([]metric.Label, 0, len(grouping))
+		for _, name := range grouping {
+			if v := labelValue(el.metric.Labels, name); v != "" {
+				labels = append(labels, metric.Label{Name: name, Value: v})
+			}
+		}
+		metric.SortLabels(labels)
+
+		mk := metric.MetricKey{Labels: labels}
+		key := mk.String()
+
+		g, ok := groups[key]
+		if !ok {
+			groups[key] = &group{metric: mk, value: el.value, count: 1}
+			order = append(order, key)
+			continue
+		}
+
+		g.count++
+		switch op {
+		case "sum", "avg":
+			g.value += el.value
+		case "max":
+			if el.value > g.value || math.IsNaN(g.value) {
+				g.value = el.value
+			}
+		case "min":
+			if el.value < g.value || math.IsNaN(g.value) {
+				g.value = el.value
+			}
+		}
+	}
+
+	out := make(vector, 0, len(groups))
+	for _, key := range order {
+		g := groups[key]
+		if op == "avg" {
+			g.value /= float64(g.count)
+		}
+		out = append(out, vectorElement{metric: g.metric, value: g.value})
+	}
+	return out
+}
+
+func binaryOp(op byte, lhs, rhs any) any {
+	ls, lok := lhs.(scalar)
+	rs, rok := rhs.(scalar)
+
+	switch {
+	case lok && rok:
+		return scalar(arithmetic(op, float64(ls), float64(rs)))
+	case rok:
+		out := make(vector, 0, len(lhs.(vector)))
+		for _, el := range lhs.(vector) {
+			out = append(out, vectorElement{
+				metric: dropName(el.metric),
+				value:  arithmetic(op, el.value, float64(rs)),
+			})
+		}
+		return out
+	case lok:
+		out := make(vector, 0, len(rhs.(vector)))
+		for _, el := range rhs.(vector) {
+			out = append(out, vectorElement{
+				metric: dropName(el.metric),
+				value:  arithmetic(op, float64(ls), el.value),
+			})
+		}
+		return out
+	}
+
+	// vectors are matched one-to-one on all of their labels.
+	rhsByLabels := make(map[string]float64)
+	for _, el := range rhs.(vector) {
+		mk := dropName(el.metric)
+		rhsByLabels[mk.String()] = el.value
+	}
+
+	var out vector
+	for _, el := range lhs.(vector) {
+		mk := dropName(el.metric)
+
+		v, ok := rhsByLabels[mk.String()]
+		if !ok {
+			continue
+		}
+
+		out = append(out, vectorElement{
+			metric: mk,
+			value:  arithmetic(op, el.value, v),
+		})
+	}
+	return out
+}
+
+func arithmetic(op byte, lhs, rhs float64) float64 {
+	switch op {
+	case '+':
+		return lhs + rhs
+	case '-':
+		return lhs - rhs
+	case '*':
+		return lhs * rhs
+	default:
+		return lhs / rhs
+	}
+}
+
+func dropName(mk metric.MetricKey) metric.MetricKey {
+	return metric.MetricKey{Labels: mk.Labels}
+}
@@ -0,0 +1,202 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/ostafen/proq/pkg/metric"
+)
+
+type argKind int
+
+const (
+	argScalar argKind = iota
+	argVector
+	argMatrix
+)
+
+type function struct {
+	args []argKind
+	call func(args []any) any
+}
+
+var functions = map[string]*function{
+	"rate": {
+		args: []argKind{argMatrix},
+		call: func(args []any) any {
+			return extrapolatedRate(args[0].(matrix), true)
+		},
+	},
+	"increase": {
+		args: []argKind{argMatrix},
+		call: func(args []any) any {
+			return extrapolatedRate(args[0].(matrix), false)
+		},
+	},
+	"irate": {
+		args: []argKind{argMatrix},
+		call: func(args []any) any {
+			return instantRate(args[0].(matrix))
+		},
+	},
+	"histogram_quantile": {
+		args: []argKind{argScalar, argVector},
+		call: func(args []any) any {
+			return histogramQuantile(float64(args[0].(scalar)), args[1].(vector))
+		},
+	},
+}
+
+func checkCall(c *Call) error {
+	fn := functions[c.Func]
+	if len(c.Args) != len(fn.args) {
+		return fmt.Errorf("%s: expected %d arguments, got %d", c.Func, len(fn.args), len(c.Args))
+	}
+
+	for i, arg := range c.Args {
+		_, isMatrix := arg.(*MatrixSelector)
+		if isMatrix != (fn.args[i] == argMatrix) {
+			if isMatrix {
+				return fmt.Errorf("%s: unexpected range vector as argument %d", c.Func, i+1)
+			}
+			return fmt.Errorf("%s: expected range vector as argument %d", c.Func, i+1)
+		}
+	}
+	return nil
+}
+
+// extrapolatedRate computes the increase of counters over the range, accounting for resets.
+// As in Prometheus, the increase is extrapolated to the boundaries of the range, unless
+// the first or last sample is too far from them.
+func extrapolatedRate(m matrix, isRate bool) vector {
+	out := make(vector, 0, len(m.series))
+	for _, rs := range m.series {
+		samples := rs.samples
+		if len(samples) < 2 {
+			continue
+		}
+
+		first, last := samples[0], samples[len(samples)-1]
+
+		increase := counterIncrease(samples)
+
+		sampledInterval := float64(last.Timestamp-first.Timestamp) / 1000
+		averageInterval := sampledInterval / float64(len(samples)-1)
+
+		durationToStart := float64(first.Timestamp-m.start) / 1000
+		durationToEnd := float64(m.end-last.Timestamp) / 1000
+
+		// counters can't be negative, so don't extrapolate before the point they would be zero.
+		if increase > 0 && first.Value >= 0 {
+			durationToZero := sampledInterval * (first.Value / increase)
+			durationToStart = min(durationToStart, durationToZero)
+		}
+
+		threshold := averageInterval * 1.1
+
+		extrapolateToInterval := sampledInterval
+		if durationToStart < threshold {
+			extrapolateToInterval += durationToStart
+		} else {
+			extrapolateToInterval += averageInterval / 2
+		}
+
+		if durationToEnd < threshold {
+			extrapolateToInterval += durationToEnd
+		} else {
+			extrapolateToInterval += averageInterval / 2
+		}
+
+		increase *= extrapolateToInterval / sampledInterval
+		if isRate {
+			increase /= float64(m.end-m.start) / 1000
+		}
+
+		out = append(out, vectorElement{
+			metric: dropName(rs.metric),
+			value:  increase,
+		})
+	}
+	return out
+}
+
+// counterIncrease sums the differences between consecutive samples. A decrease
+// is a counter reset, after which the counter restarted from zero.
+func counterIncrease(samples []metric.Sample) float64 {
+	increase := 0.0
+	for i := 1; i < len(samples); i++ {
+		prev, curr := samples[i-1].Value, samples[i].Value
+		if curr < prev {
+			increase += curr
+		} else {
+			increase += curr - prev
+		}
+	}
+	return increase
+}
+
+// instantRate computes the per-second rate from the last two samples of the range.
+func instantRate(m matrix) vector {
+	out := make(vector, 0, len(m.series))
+	for _, rs := range m.series {
+		samples := rs.samples
+		if len(samples) < 2 {
+			continue
+		}
+
+		last := samples[len(samples)-1]
+		prev := samples[len(samples)-2]
+
+		out = append(out, vectorElement{
+			metric: dropName(rs.metric),
+			value:  counterIncrease(samples[len(samples)-2:]) / (float64(last.Timestamp-prev.Timestamp) / 1000),
+		})
+	}
+	return out
+}
+
+// histogramQuantile groups the bucket series of the vector by their labels, except "le",
+// and estimates the quantile of each histogram.
+func histogramQuantile(q float64, vec vector) vector {
+	type histogram struct {
+		metric  metric.MetricKey
+		buckets []metric.Bucket
+	}
+
+	var order []string
+	histograms := make(map[string]*histogram)
+	for _, el := range vec {
+		le, err := strconv.ParseFloat(labelValue(el.metric.Labels, "le"), 64)
+		if err != nil {
+			continue
+		}
+
+		labels := make([]metric.Label, 0, len(el.metric.Labels))
+		for _, l := range el.metric.Labels {
+			if l.Name != "le" {
+				labels = append(labels, l)
+			}
+		}
+
+		mk := metric.MetricKey{Labels: labels}
+		key := mk.String()
+
+		h, ok := histograms[key]
+		if !ok {
+			h = &histogram{metric: mk}
+			histograms[key] = h
+			order = append(order, key)
+		}
+		h.buckets = append(h.buckets, metric.Bucket{UpperBound: le, Count: el.value})
+	}
+
+	out := make(vector, 0, len(histograms))
+	for _, key := range order {
+		h := histograms[key]
+		out = append(out, vectorElement{
+			metric: h.metric,
+			value:  metric.BucketQuantile(q, h.buckets),
+		})
+	}
+	return out
+}
@@ -0,0 +1,471 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidQuery = errors.New("invalid query")
+
+// ParseError describes a malformed query, along with the position at which parsing stopped.
+// It wraps ErrInvalidQuery.
+type ParseError struct {
+	Pos int
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("col %d: %s", e.Pos+1, e.Msg)
+}
+
+func (e *ParseError) Unwrap() error {
+	return ErrInvalidQuery
+}
+
+const metricNameLabel = "__name__"
+
+var aggregations = map[string]bool{
+	"sum": true,
+	"avg": true,
+	"max": true,
+	"min": true,
+}
+
+// Parse parses a query written in the supported subset of PromQL:
+// selectors with label matchers, range selectors, the functions rate, irate,
+// increase and histogram_quantile, the sum, avg, max and min aggregations
+// with an optional "by" clause, and the +, -, * and / operators.
+func Parse(input string) (Expr, error) {
+	p := &parser{input: input}
+
+	e, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+	if !p.eof() {
+		return nil, p.errorf("unexpected character '%c'", p.peek())
+	}
+	return e, nil
+}
+
+// ParseSelector parses a vector selector, such as `http_requests_total{code="200"}`.
+func ParseSelector(input string) (*VectorSelector, error) {
+	e, err := Parse(input)
+	if err != nil {
+		return nil, err
+	}
+
+	vs, ok := e.(*VectorSelector)
+	if !ok {
+		return nil, fmt.Errorf("%w: \"%s\" is not a series selector", ErrInvalidQuery, input)
+	}
+	return vs, nil
+}
+
+type parser struct {
+	input string
+	pos   int
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return &ParseError{
+		Pos: p.pos,
+		Msg: fmt.Sprintf(format, args...),
+	}
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func (p *parser) skipSpace() {
+	for !p.eof() && isSpace(p.input[p.pos]) {
+		p.pos++
+	}
+}
+
+// consume skips the given token, if it comes next.
+func (p *parser) consume(tok string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.input[p.pos:], tok) {
+		p.pos += len(tok)
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(tok string) error {
+	if !p.consume(tok) {
+		if p.eof() {
+			return p.errorf("expected \"%s\", got end of input", tok)
+		}
+		return p.errorf("expected \"%s\", got '%c'", tok, p.peek())
+	}
+	return nil
+}
+
+// expr parses additions and subtractions, which bind less tightly than multiplications and divisions.
+func (p *parser) expr() (Expr, error) {
+	lhs, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		p.skipSpace()
+
+		op := p.peek()
+		if op != '+' && op != '-' {
+			return lhs, nil
+		}
+		p.pos++
+
+		rhs, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &BinaryExpr{Op: op, LHS: lhs, RHS: rhs}
+	}
+}
+
+func (p *parser) term() (Expr, error) {
+	lhs, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		p.skipSpace()
+
+		op := p.peek()
+		if op != '*' && op != '/' {
+			return lhs, nil
+		}
+		p.pos++
+
+		rhs, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &BinaryExpr{Op: op, LHS: lhs, RHS: rhs}
+	}
+}
+
+func (p *parser) unary() (Expr, error) {
+	if p.consume("-") {
+		e, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+
+		if n, ok := e.(*NumberLiteral); ok {
+			return &NumberLiteral{Val: -n.Val}, nil
+		}
+		return &BinaryExpr{Op: '*', LHS: &NumberLiteral{Val: -1}, RHS: e}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (Expr, error) {
+	p.skipSpace()
+
+	c := p.peek()
+	switch {
+	case p.eof():
+		return nil, p.errorf("unexpected end of input")
+	case c == '(':
+		p.pos++
+
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expect(")")
+	case c == '{':
+		return p.selector("")
+	case isDigit(c) || c == '.':
+		return p.number()
+	case isNameChar(c, true):
+		return p.identifierExpr()
+	}
+	return nil, p.errorf("unexpected character '%c'", c)
+}
+
+func (p *parser) number() (Expr, error) {
+	start := p.pos
+	for !p.eof() && (isDigit(p.peek()) || strings.IndexByte(".eE", p.peek()) >= 0 ||
+		(strings.IndexByte("+-", p.peek()) >= 0 && strings.IndexByte("eE", p.input[p.pos-1]) >= 0)) {
+		p.pos++
+	}
+
+	text := p.input[start:p.pos]
+
+	v, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		p.pos = start
+		return nil, p.errorf("invalid number \"%s\"", text)
+	}
+	return &NumberLiteral{Val: v}, nil
+}
+
+func (p *parser) identifierExpr() (Expr, error) {
+	start := p.pos
+	name := p.identifier()
+
+	switch {
+	case aggregations[name]:
+		return p.aggregation(name)
+	case functions[name] != nil:
+		p.skipSpace()
+		if p.peek() == '(' {
+			return p.call(name)
+		}
+	case strings.EqualFold(name, "inf"), strings.EqualFold(name, "nan"):
+		v, _ := strconv.ParseFloat(name, 64)
+		return &NumberLiteral{Val: v}, nil
+	}
+
+	p.pos = start
+	return p.selector(p.identifier())
+}
+
+func (p *parser) identifier() string {
+	start := p.pos
+	for !p.eof() && isNameChar(p.peek(), p.pos == start) {
+		p.pos++
+	}
+	return p.input[start:p.pos]
+}
+
+func (p *parser) aggregation(op string) (Expr, error) {
+	grouping, err := p.grouping()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	e, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	// the "by" clause can either precede or follow the argument.
+	if grouping == nil {
+		if grouping, err = p.grouping(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &AggregateExpr{
+		Op:       op,
+		Grouping: grouping,
+		Expr:     e,
+	}, nil
+}
+
+func (p *parser) grouping() ([]string, error) {
+	p.skipSpace()
+
+	start := p.pos
+	if p.identifier() != "by" {
+		p.pos = start
+		return nil, nil
+	}
+
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	labels := []string{}
+	for !p.consume(")") {
+		if len(labels) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+
+		p.skipSpace()
+
+		name := p.identifier()
+		if name == "" {
+			return nil, p.errorf("expected label name")
+		}
+		labels = append(labels, name)
+	}
+	return labels, nil
+}
+
+func (p *parser) call(name string) (Expr, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	var args []Expr
+	for !p.consume(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, e)
+	}
+
+	call := &Call{Func: name, Args: args}
+	if err := checkCall(call); err != nil {
+		return nil, p.errorf("%s", err)
+	}
+	return call, nil
+}
+
+func (p *parser) selector(name string) (Expr, error) {
+	vs := &VectorSelector{Name: name}
+
+	if p.consume("{") {
+		for n := 0; !p.consume("}"); n++ {
+			if n > 0 {
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+
+				// a trailing comma is allowed.
+				if p.consume("}") {
+					break
+				}
+			}
+
+			m, err := p.matcher()
+			if err != nil {
+				return nil, err
+			}
+
+			if m.Name == metricNameLabel && m.Type == MatchEqual {
+				vs.Name = m.Value
+				continue
+			}
+			vs.Matchers = append(vs.Matchers, m)
+		}
+	}
+
+	if vs.Name == "" && len(vs.Matchers) == 0 {
+		return nil, p.errorf("empty selector")
+	}
+
+	if !p.consume("[") {
+		return vs, nil
+	}
+
+	end := strings.IndexByte(p.input[p.pos:], ']')
+	if end < 0 {
+		return nil, p.errorf("unterminated range")
+	}
+
+	d, err := time.ParseDuration(strings.TrimSpace(p.input[p.pos : p.pos+end]))
+	if err != nil || d <= 0 {
+		return nil, p.errorf("invalid range \"%s\"", p.input[p.pos:p.pos+end])
+	}
+	p.pos += end + 1
+
+	return &MatrixSelector{Vector: vs, Range: d}, nil
+}
+
+func (p *parser) matcher() (*Matcher, error) {
+	p.skipSpace()
+
+	name := p.identifier()
+	if name == "" {
+		return nil, p.errorf("expected label name")
+	}
+
+	var t MatchType
+	switch {
+	case p.consume(string(MatchRegexp)):
+		t = MatchRegexp
+	case p.consume(string(MatchNotRegexp)):
+		t = MatchNotRegexp
+	case p.consume(string(MatchNotEqual)):
+		t = MatchNotEqual
+	case p.consume(string(MatchEqual)):
+		t = MatchEqual
+	default:
+		return nil, p.errorf("expected label matching operator")
+	}
+
+	value, err := p.quoted()
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := NewMatcher(t, name, value)
+	if err != nil {
+		return nil, p.errorf("%s", err)
+	}
+	return m, nil
+}
+
+func (p *parser) quoted() (string, error) {
+	p.skipSpace()
+
+	quote := p.peek()
+	if quote != '"' && quote != '\'' {
+		return "", p.errorf("expected quoted string")
+	}
+
+	var sb strings.Builder
+	for i := p.pos + 1; i < len(p.input); i++ {
+		c := p.input[i]
+		switch {
+		case c == quote:
+			p.pos = i + 1
+			return sb.String(), nil
+		case c == '\\' && i+1 < len(p.input):
+			i++
+			switch p.input[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			default:
+				sb.WriteByte(p.input[i])
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isNameChar(c byte, first bool) bool {
+	return c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (!first && isDigit(c))
+}
@@ -0,0 +1,229 @@
+package query
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ostafen/proq/pkg/metric"
+)
+
+type testStorage map[string]Series
+
+func (st testStorage) Series(onSeries func(key metric.MetricKey)) {
+	for _, s := range st {
+		onSeries(s.Metric)
+	}
+}
+
+func (st testStorage) Samples(key metric.MetricKey, onSample func(metric.Sample)) int {
+	s, ok := st[key.String()]
+	if !ok {
+		return -1
+	}
+
+	for _, sample := range s.Samples {
+		onSample(sample)
+	}
+	return len(s.Samples)
+}
+
+func (st testStorage) add(name string, labels []metric.Label, values ...float64) {
+	mk := metric.MetricKey{Name: name, Labels: labels}
+
+	s := Series{Metric: mk}
+	for i, v := range values {
+		s.Samples = append(s.Samples, metric.Sample{Timestamp: int64(i+1) * 1000, Value: v})
+	}
+	st[mk.String()] = s
+}
+
+func labels(kv ...string) []metric.Label {
+	var out []metric.Label
+	for i := 0; i < len(kv); i += 2 {
+		out = append(out, metric.Label{Name: kv[i], Value: kv[i+1]})
+	}
+	metric.SortLabels(out)
+	return out
+}
+
+func TestParse(t *testing.T) {
+	type testCase struct {
+		query string
+		err   bool
+	}
+
+	cases := []testCase{
+		{query: `http_requests_total`},
+		{query: `http_requests_total{status="200", method=~"get|post",}`},
+		{query: `{__name__="http_requests_total", status!="500"}`},
+		{query: `rate(http_requests_total[1m])`},
+		{query: `sum by (status) (rate(http_requests_total[5m]))`},
+		{query: `avg(up) by (job)`},
+		{query: `histogram_quantile(0.99, sum by (le) (rate(latency_bucket[1m])))`},
+		{query: `errors_total / requests_total * 100`},
+		{query: `-up + 1e3`},
+		{query: `rate(up)`, err: true},
+		{query: `rate(up[1m], 1)`, err: true},
+		{query: `up{status="200"`, err: true},
+		{query: `up[1x]`, err: true},
+		{query: `{}`, err: true},
+		{query: `up{status=~"("}`, err: true},
+		{query: `sum(up`, err: true},
+	}
+
+	for _, c := range cases {
+		_, err := Parse(c.query)
+		if c.err {
+			require.ErrorIs(t, err, ErrInvalidQuery, c.query)
+		} else {
+			require.NoError(t, err, c.query)
+		}
+	}
+}
+
+func TestParsePrecedence(t *testing.T) {
+	e, err := Parse(`1 + 2 * 3`)
+	require.NoError(t, err)
+
+	require.Equal(t, &BinaryExpr{
+		Op:  '+',
+		LHS: &NumberLiteral{Val: 1},
+		RHS: &BinaryExpr{
+			Op:  '*',
+			LHS: &NumberLiteral{Val: 2},
+			RHS: &NumberLiteral{Val: 3},
+		},
+	}, e)
+}
+
+func eval(t *testing.T, st Storage, query string, at int64) []Series {
+	e, err := Parse(query)
+	require.NoError(t, err)
+
+	ts := time.UnixMilli(at)
+
+	res, err := Eval(st, e, ts, ts, time.Second)
+	require.NoError(t, err)
+	return res
+}
+
+func values(series []Series) map[string]float64 {
+	out := make(map[string]float64)
+	for _, s := range series {
+		out[s.Metric.String()] = s.Samples[len(s.Samples)-1].Value
+	}
+	return out
+}
+
+func TestEval(t *testing.T) {
+	st := testStorage{}
+	st.add("requests_total", labels("status", "200"), 10, 20, 30, 40, 50)
+	st.add("requests_total", labels("status", "500"), 1, 2, 3, 4, 5)
+	st.add("errors_total", labels("status", "500"), 2, 2, 2, 2, 2)
+
+	require.Equal(t, map[string]float64{
+		`requests_total{status="500"}`: 5,
+	}, values(eval(t, st, `requests_total{status=~"5.."}`, 5000)))
+
+	require.Equal(t, map[string]float64{
+		`{status="500"}`: 2.5,
+	}, values(eval(t, st, `requests_total / errors_total`, 5000)))
+
+	require.Equal(t, map[string]float64{
+		``: 55,
+	}, values(eval(t, st, `sum(requests_total)`, 5000)))
+
+	require.Equal(t, map[string]float64{
+		`{status="200"}`: 100,
+		`{status="500"}`: 10,
+	}, values(eval(t, st, `requests_total * 2`, 5000)))
+
+	require.Equal(t, map[string]float64{
+		`{status="200"}`: 10,
+		`{status="500"}`: 1,
+	}, values(eval(t, st, `irate(requests_total[10s])`, 5000)))
+
+	require.Equal(t, map[string]float64{
+		`{status="200"}`: 40,
+		`{status="500"}`: 4,
+	}, values(eval(t, st, `increase(requests_total[4s])`, 5000)))
+
+	require.Equal(t, map[string]float64{
+		``: 11,
+	}, values(eval(t, st, `sum(rate(requests_total[4s]))`, 5000)))
+
+	require.Equal(t, map[string]float64{
+		``: 7,
+	}, values(eval(t, st, `1 + 2 * 3`, 5000)))
+
+	require.Empty(t, eval(t, st, `requests_total`, 5000+LookbackDelta.Milliseconds()+1))
+
+	_, err := Eval(st, &NumberLiteral{Val: 1}, time.UnixMilli(1000), time.UnixMilli(1000), time.Microsecond)
+	require.Error(t, err)
+}
+
+func TestEvalCounterReset(t *testing.T) {
+	st := testStorage{}
+	st.add("requests_total", nil, 10, 20, 5, 15)
+
+	// the 5 sample resets the counter: the increase over the
+	// last three samples is 5 + 10, extrapolated from 2s to 3s.
+	require.Equal(t, map[string]float64{
+		``: 22.5,
+	}, values(eval(t, st, `increase(requests_total[3s])`, 4000)))
+
+	st.add("requests_total", nil, 10, 20, 5)
+
+	require.Equal(t, map[string]float64{
+		``: 5,
+	}, values(eval(t, st, `irate(requests_total[3s])`, 3000)))
+}
+
+func TestEvalAggregation(t *testing.T) {
+	st := testStorage{}
+	st.add("queue_depth", labels("job", "api", "instance", "a"), 1)
+	st.add("queue_depth", labels("job", "api", "instance", "b"), 3)
+	st.add("queue_depth", labels("job", "worker", "instance", "c"), 10)
+
+	for op, expected := range map[string]map[string]float64{
+		"sum": {`{job="api"}`: 4, `{job="worker"}`: 10},
+		"avg": {`{job="api"}`: 2, `{job="worker"}`: 10},
+		"max": {`{job="api"}`: 3, `{job="worker"}`: 10},
+		"min": {`{job="api"}`: 1, `{job="worker"}`: 10},
+	} {
+		require.Equal(t, expected, values(eval(t, st, op+` by (job) (queue_depth)`, 1000)), op)
+	}
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	st := testStorage{}
+	st.add("latency_bucket", labels("le", "0.1"), 50)
+	st.add("latency_bucket", labels("le", "0.5"), 90)
+	st.add("latency_bucket", labels("le", "1"), 100)
+	st.add("latency_bucket", labels("le", "+Inf"), 100)
+
+	require.InDelta(t, 0.1, values(eval(t, st, `histogram_quantile(0.5, latency_bucket)`, 1000))[""], 1e-9)
+	require.InDelta(t, 0.3, values(eval(t, st, `histogram_quantile(0.7, latency_bucket)`, 1000))[""], 1e-9)
+	require.InDelta(t, 1, values(eval(t, st, `histogram_quantile(1, latency_bucket)`, 1000))[""], 1e-9)
+	require.True(t, math.IsInf(values(eval(t, st, `histogram_quantile(2, latency_bucket)`, 1000))[""], 1))
+}
+
+func TestEvalRange(t *testing.T) {
+	st := testStorage{}
+	st.add("up", nil, 1, 1, 1)
+
+	e, err := Parse(`up`)
+	require.NoError(t, err)
+
+	res, err := Eval(st, e, time.UnixMilli(1000), time.UnixMilli(3000), time.Second)
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	require.Equal(t, []metric.Sample{
+		{Timestamp: 1000, Value: 1},
+		{Timestamp: 2000, Value: 1},
+		{Timestamp: 3000, Value: 1},
+	}, res[0].Samples)
+}
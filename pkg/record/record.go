@@ -0,0 +1,410 @@
+// Package record implements an append-only file format for scraped samples.
+//
+// A record file starts with a magic string, followed by a sequence of entries.
+// A series entry adds a series to the index of the file, assigning it the next
+// id, starting from zero. A batch entry holds the samples scraped at the same time,
+// each referencing its series by id. Timestamps are stored as the difference from
+// the one of the previous batch, so that a file is mostly made of series ids and values:
+//
+//	file   := magic entry*
+//	entry  := 'S' string labels string | 'B' varint(ts delta) uvarint(n) (uvarint(id) float64)*
+//	labels := uvarint(n) (string string)*
+//	string := uvarint(len) bytes
+package record
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+
+	"github.com/ostafen/proq/pkg/metric"
+)
+
+const magic = "PROQREC\x01"
+
+const (
+	entrySeries = 'S'
+	entryBatch  = 'B'
+)
+
+var ErrInvalidRecord = errors.New("invalid record file")
+
+// Series is a series of the index of a record file.
+type Series struct {
+	Name   string
+	Labels []metric.Label
+	Type   metric.MetricType
+}
+
+func (s *Series) Key() metric.MetricKey {
+	return metric.MetricKey{Name: s.Name, Labels: s.Labels}
+}
+
+type Sample struct {
+	Series *Series
+	Value  float64
+}
+
+// Batch holds the samples recorded at the same time.
+type Batch struct {
+	Timestamp int64
+	Samples   []Sample
+}
+
+// RawMetrics converts the batch to the samples of an exposition.
+func (b *Batch) RawMetrics() []metric.RawMetric {
+	metrics := make([]metric.RawMetric, len(b.Samples))
+	for i, s := range b.Samples {
+		metrics[i] = metric.RawMetric{
+			Name:      s.Series.Name,
+			Labels:    slices.Clone(s.Series.Labels),
+			Value:     s.Value,
+			Timestamp: b.Timestamp,
+		}
+	}
+	return metrics
+}
+
+// Metadata returns the types of the recorded series, indexed by family name as in an exposition.
+func (b *Batch) Metadata() map[string]metric.Metadata {
+	metadata := make(map[string]metric.Metadata)
+	for _, s := range b.Samples {
+		name := s.Series.Name
+
+		switch s.Series.Type {
+		case "":
+			continue
+		case metric.TypeHistogram, metric.TypeGaugeHistogram, metric.TypeSummary:
+			name = metric.TrimHistogramSuffix(name)
+		}
+		metadata[name] = metric.Metadata{Name: name, Type: s.Series.Type}
+	}
+	return metadata
+}
+
+// Writer appends samples to a record file.
+type Writer struct {
+	f *os.File
+	w *bufio.Writer
+
+	index  map[string]int
+	lastTs int64
+
+	buf []byte
+}
+
+// Open opens a record file for appending, creating it if it doesn't exist. The index of an
+// existing file is loaded, and any partially written entry at its end is discarded.
+func Open(path string) (*Writer, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Writer{
+		f:     f,
+		index: make(map[string]int),
+	}
+
+	if err := w.recover(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	w.w = bufio.NewWriter(f)
+	return w, nil
+}
+
+func (w *Writer) recover() error {
+	info, err := w.f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if info.Size() == 0 {
+		_, err := w.f.WriteString(magic)
+		return err
+	}
+
+	r, err := NewReader(w.f)
+	if err != nil {
+		return err
+	}
+
+	for {
+		_, err := r.Next()
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for id, s := range r.series {
+		key := s.Key()
+		w.index[key.String()] = id
+	}
+	w.lastTs = r.ts
+
+	if err := w.f.Truncate(r.offset); err != nil {
+		return err
+	}
+	_, err = w.f.Seek(r.offset, io.SeekStart)
+	return err
+}
+
+// Append writes the samples of a scrape, grouping them by timestamp. The metadata is used
+// to record the type of the series being added to the index, and may be nil.
+func (w *Writer) Append(samples []metric.RawMetric, metadata map[string]metric.Metadata) error {
+	type sample struct {
+		id    int
+		value float64
+	}
+
+	var timestamps []int64
+	batches := make(map[int64][]sample)
+	for _, m := range samples {
+		key := metric.MetricKey{Name: m.Name, Labels: slices.Clone(m.Labels)}
+		metric.SortLabels(key.Labels)
+
+		id, ok := w.index[key.String()]
+		if !ok {
+			md, _ := metric.LookupMetadata(metadata, m.Name)
+			if err := w.writeSeries(key, md.Type); err != nil {
+				return err
+			}
+
+			id = len(w.index)
+			w.index[key.String()] = id
+		}
+
+		if _, ok := batches[m.Timestamp]; !ok {
+			timestamps = append(timestamps, m.Timestamp)
+		}
+		batches[m.Timestamp] = append(batches[m.Timestamp], sample{id: id, value: m.Value})
+	}
+
+	slices.Sort(timestamps)
+
+	for _, ts := range timestamps {
+		b := append(w.buf[:0], entryBatch)
+		b = binary.AppendVarint(b, ts-w.lastTs)
+		b = binary.AppendUvarint(b, uint64(len(batches[ts])))
+		for _, s := range batches[ts] {
+			b = binary.AppendUvarint(b, uint64(s.id))
+			b = binary.LittleEndian.AppendUint64(b, math.Float64bits(s.value))
+		}
+		w.buf = b
+
+		if _, err := w.w.Write(b); err != nil {
+			return err
+		}
+		w.lastTs = ts
+	}
+	return w.w.Flush()
+}
+
+func (w *Writer) writeSeries(key metric.MetricKey, typ metric.MetricType) error {
+	b := append(w.buf[:0], entrySeries)
+	b = appendString(b, key.Name)
+	b = binary.AppendUvarint(b, uint64(len(key.Labels)))
+	for _, l := range key.Labels {
+		b = appendString(b, l.Name)
+		b = appendString(b, l.Value)
+	}
+	b = appendString(b, string(typ))
+	w.buf = b
+
+	_, err := w.w.Write(b)
+	return err
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func (w *Writer) Close() error {
+	if err := w.w.Flush(); err != nil {
+		w.f.Close()
+		return err
+	}
+	return w.f.Close()
+}
+
+// Reader reads the batches of a record file, loading its index along the way.
+type Reader struct {
+	r *countingReader
+
+	series []*Series
+	ts     int64
+
+	// offset is the position of the end of the last complete entry.
+	offset int64
+}
+
+func NewReader(r io.Reader) (*Reader, error) {
+	rd := &Reader{r: &countingReader{r: bufio.NewReader(r)}}
+
+	buf := make([]byte, len(magic))
+	if _, err := io.ReadFull(rd.r, buf); err != nil || string(buf) != magic {
+		return nil, ErrInvalidRecord
+	}
+
+	rd.offset = rd.r.n
+	return rd, nil
+}
+
+// Next returns the next batch of the file, or io.EOF at its end. An entry which
+// was only partially written is reported as io.ErrUnexpectedEOF.
+func (r *Reader) Next() (*Batch, error) {
+	for {
+		kind, err := r.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+
+		switch kind {
+		case entrySeries:
+			s, err := r.readSeries()
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			r.series = append(r.series, s)
+			r.offset = r.r.n
+		case entryBatch:
+			b, err := r.readBatch()
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			r.offset = r.r.n
+			return b, nil
+		default:
+			return nil, fmt.Errorf("%w: unknown entry %q at offset %d", ErrInvalidRecord, kind, r.r.n-1)
+		}
+	}
+}
+
+// Series returns the series read so far.
+func (r *Reader) Series() []*Series {
+	return r.series
+}
+
+func (r *Reader) readSeries() (*Series, error) {
+	name, err := r.readString()
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := r.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make([]metric.Label, n)
+	for i := range labels {
+		if labels[i].Name, err = r.readString(); err != nil {
+			return nil, err
+		}
+		if labels[i].Value, err = r.readString(); err != nil {
+			return nil, err
+		}
+	}
+
+	typ, err := r.readString()
+	if err != nil {
+		return nil, err
+	}
+	return &Series{Name: name, Labels: labels, Type: metric.MetricType(typ)}, nil
+}
+
+func (r *Reader) readBatch() (*Batch, error) {
+	delta, err := binary.ReadVarint(r.r)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := r.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Batch{
+		Timestamp: r.ts + delta,
+		Samples:   make([]Sample, n),
+	}
+
+	var buf [8]byte
+	for i := range b.Samples {
+		id, err := r.readUvarint()
+		if err != nil {
+			return nil, err
+		}
+
+		if id >= uint64(len(r.series)) {
+			return nil, fmt.Errorf("%w: unknown series id %d", ErrInvalidRecord, id)
+		}
+
+		if _, err := io.ReadFull(r.r, buf[:]); err != nil {
+			return nil, err
+		}
+
+		b.Samples[i] = Sample{
+			Series: r.series[id],
+			Value:  math.Float64frombits(binary.LittleEndian.Uint64(buf[:])),
+		}
+	}
+
+	r.ts = b.Timestamp
+	return b, nil
+}
+
+func (r *Reader) readUvarint() (uint64, error) {
+	return binary.ReadUvarint(r.r)
+}
+
+func (r *Reader) readString() (string, error) {
+	n, err := r.readUvarint()
+	if err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r.r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// countingReader keeps track of the offset within the file.
+type countingReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+func (cr *countingReader) ReadByte() (byte, error) {
+	b, err := cr.r.ReadByte()
+	if err == nil {
+		cr.n++
+	}
+	return b, err
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,117 @@
+package record
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ostafen/proq/pkg/metric"
+)
+
+func readAll(t *testing.T, path string) []*Batch {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	r, err := NewReader(f)
+	require.NoError(t, err)
+
+	var batches []*Batch
+	for {
+		b, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return batches
+		}
+		require.NoError(t, err)
+		batches = append(batches, b)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.rec")
+
+	w, err := Open(path)
+	require.NoError(t, err)
+
+	metadata := map[string]metric.Metadata{
+		"requests": {Name: "requests", Type: metric.TypeCounter},
+	}
+
+	err = w.Append([]metric.RawMetric{
+		{Name: "requests_total", Labels: []metric.Label{{Name: "path", Value: "/"}, {Name: "code", Value: "200"}}, Value: 10, Timestamp: 2000},
+		{Name: "temperature", Value: 21.5, Timestamp: 1000},
+	}, metadata)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	// the index is loaded when appending to an existing file.
+	w, err = Open(path)
+	require.NoError(t, err)
+
+	err = w.Append([]metric.RawMetric{
+		{Name: "requests_total", Labels: []metric.Label{{Name: "code", Value: "200"}, {Name: "path", Value: "/"}}, Value: 12, Timestamp: 3000},
+	}, nil)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	batches := readAll(t, path)
+	require.Len(t, batches, 3)
+
+	require.Equal(t, int64(1000), batches[0].Timestamp)
+	require.Equal(t, []metric.RawMetric{{Name: "temperature", Labels: []metric.Label{}, Value: 21.5, Timestamp: 1000}}, batches[0].RawMetrics())
+
+	require.Equal(t, int64(2000), batches[1].Timestamp)
+	require.Equal(t, batches[1].Samples[0].Series, batches[2].Samples[0].Series)
+
+	series := batches[2].Samples[0].Series
+	require.Equal(t, metric.TypeCounter, series.Type)
+	require.Equal(t, `requests_total{code="200", path="/"}`, (&metric.MetricKey{Name: series.Name, Labels: series.Labels}).String())
+	require.Equal(t, int64(3000), batches[2].Timestamp)
+	require.Equal(t, 12.0, batches[2].Samples[0].Value)
+}
+
+func TestRecordTruncated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.rec")
+
+	w, err := Open(path)
+	require.NoError(t, err)
+	require.NoError(t, w.Append([]metric.RawMetric{{Name: "up", Value: 1, Timestamp: 1000}}, nil))
+	require.NoError(t, w.Close())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+
+	// simulate a crash in the middle of writing a batch.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{entryBatch, 2, 1})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	w, err = Open(path)
+	require.NoError(t, err)
+
+	after, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, info.Size(), after.Size())
+
+	require.NoError(t, w.Append([]metric.RawMetric{{Name: "up", Value: 0, Timestamp: 2000}}, nil))
+	require.NoError(t, w.Close())
+
+	batches := readAll(t, path)
+	require.Len(t, batches, 2)
+	require.Equal(t, int64(2000), batches[1].Timestamp)
+	require.Equal(t, 0.0, batches[1].Samples[0].Value)
+}
+
+func TestInvalidRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.txt")
+	require.NoError(t, os.WriteFile(path, []byte("up 1\n"), 0o644))
+
+	_, err := Open(path)
+	require.ErrorIs(t, err, ErrInvalidRecord)
+}
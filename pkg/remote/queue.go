@@ -0,0 +1,278 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ostafen/proq/pkg/metric"
+)
+
+// QueueConfig controls how samples are batched and sent by a queue.
+type QueueConfig struct {
+	// Capacity bounds the samples waiting to be sent. Samples enqueued
+	// when the queue is full are dropped.
+	Capacity int
+
+	// MaxBatchSize bounds the samples sent by a single request, and
+	// BatchDeadline is how long samples wait for a batch to fill up.
+	MaxBatchSize  int
+	BatchDeadline time.Duration
+
+	// failed requests are retried after a backoff, which starts at MinBackoff
+	// and is doubled after each attempt, up to MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// Timeout bounds each request.
+	Timeout time.Duration
+}
+
+var DefaultQueueConfig = QueueConfig{
+	Capacity:      10000,
+	MaxBatchSize:  2000,
+	BatchDeadline: 5 * time.Second,
+	MinBackoff:    30 * time.Millisecond,
+	MaxBackoff:    5 * time.Second,
+	Timeout:       30 * time.Second,
+}
+
+// closeTimeout bounds the time spent sending the queued samples on Close.
+const closeTimeout = 5 * time.Second
+
+// QueueStats counts the samples handled by a queue.
+type QueueStats struct {
+	// Sent counts the samples accepted by the receiver.
+	Sent uint64
+	// Dropped counts the samples discarded because the queue was full.
+	Dropped uint64
+	// Failed counts the samples rejected by the receiver, or which could not be
+	// sent before the queue was closed.
+	Failed uint64
+	// Retries counts the requests sent again after a recoverable error.
+	Retries uint64
+	// Pending is the number of samples waiting to be sent.
+	Pending int
+	// LastError is the error of the last failed request, if any.
+	LastError error
+}
+
+// Queue sends samples to a remote-write receiver in the background, batching them
+// and retrying the requests which fail with recoverable errors.
+type Queue struct {
+	url    string
+	cfg    QueueConfig
+	client *http.Client
+
+	samples chan metric.RawMetric
+
+	sent, dropped, failed, retries atomic.Uint64
+
+	mu      sync.Mutex
+	lastErr error
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewQueue returns a queue sending samples to the receiver at url.
+func NewQueue(url string, cfg QueueConfig) *Queue {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	q := &Queue{
+		url:     url,
+		cfg:     cfg,
+		client:  &http.Client{},
+		samples: make(chan metric.RawMetric, cfg.Capacity),
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	go q.run()
+	return q
+}
+
+// Enqueue adds samples to the queue, without blocking. The samples which
+// don't fit in the queue are dropped.
+func (q *Queue) Enqueue(samples []metric.RawMetric) {
+	for _, s := range samples {
+		select {
+		case q.samples <- s:
+		default:
+			q.dropped.Add(1)
+		}
+	}
+}
+
+func (q *Queue) Stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return QueueStats{
+		Sent:      q.sent.Load(),
+		Dropped:   q.dropped.Load(),
+		Failed:    q.failed.Load(),
+		Retries:   q.retries.Load(),
+		Pending:   len(q.samples),
+		LastError: q.lastErr,
+	}
+}
+
+// Close sends the queued samples and stops the queue. Samples which can't be sent
+// within a few seconds are given up. Enqueue must not be called after Close.
+func (q *Queue) Close() {
+	close(q.samples)
+
+	select {
+	case <-q.done:
+	case <-time.After(closeTimeout):
+		q.cancel()
+		<-q.done
+	}
+	q.cancel()
+}
+
+func (q *Queue) run() {
+	defer close(q.done)
+
+	batch := make([]metric.RawMetric, 0, q.cfg.MaxBatchSize)
+
+	timer := time.NewTimer(q.cfg.BatchDeadline)
+	defer timer.Stop()
+
+	flush := func() {
+		if len(batch) > 0 {
+			q.send(batch)
+			batch = batch[:0]
+		}
+		timer.Reset(q.cfg.BatchDeadline)
+	}
+
+	for {
+		select {
+		case s, ok := <-q.samples:
+			if !ok {
+				flush()
+				return
+			}
+
+			batch = append(batch, s)
+			if len(batch) >= q.cfg.MaxBatchSize {
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		}
+	}
+}
+
+// recoverableError is returned for the requests which should be retried.
+type recoverableError struct {
+	error
+}
+
+// send delivers a batch, retrying until it succeeds, fails with an unrecoverable
+// error or the queue is stopped.
+func (q *Queue) send(batch []metric.RawMetric) {
+	body := Encode(newWriteRequest(batch))
+
+	backoff := q.cfg.MinBackoff
+	for {
+		err := q.post(body)
+		if err == nil {
+			q.sent.Add(uint64(len(batch)))
+			return
+		}
+
+		q.mu.Lock()
+		q.lastErr = err
+		q.mu.Unlock()
+
+		var rerr recoverableError
+		if !errors.As(err, &rerr) {
+			q.failed.Add(uint64(len(batch)))
+			return
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-q.ctx.Done():
+			q.failed.Add(uint64(len(batch)))
+			return
+		}
+
+		q.retries.Add(1)
+		backoff = min(backoff*2, q.cfg.MaxBackoff)
+	}
+}
+
+func (q *Queue) post(body []byte) error {
+	ctx, cancel := context.WithTimeout(q.ctx, q.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, q.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "proq")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	resp, err := q.client.Do(req)
+	if err != nil {
+		return recoverableError{err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+	err = fmt.Errorf("remote write returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+
+	// server errors and rate limiting are temporary, while other errors
+	// mean that the request is rejected.
+	if resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests {
+		return recoverableError{err}
+	}
+	return err
+}
+
+// newWriteRequest groups samples by series.
+func newWriteRequest(samples []metric.RawMetric) *WriteRequest {
+	req := &WriteRequest{}
+
+	index := make(map[string]int)
+	for _, m := range samples {
+		labels := make([]metric.Label, 0, len(m.Labels)+1)
+		labels = append(labels, metric.Label{Name: metricNameLabel, Value: m.Name})
+		labels = append(labels, m.Labels...)
+		metric.SortLabels(labels)
+
+		mk := metric.MetricKey{Labels: labels}
+		k := mk.String()
+
+		i, ok := index[k]
+		if !ok {
+			i = len(req.Timeseries)
+			index[k] = i
+			req.Timeseries = append(req.Timeseries, TimeSeries{Labels: labels})
+		}
+
+		ts := &req.Timeseries[i]
+		ts.Samples = append(ts.Samples, metric.Sample{Timestamp: m.Timestamp, Value: m.Value})
+	}
+	return req
+}
@@ -0,0 +1,59 @@
+package remote
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/golang/snappy"
+)
+
+// maxRequestSize bounds the size of a compressed request.
+const maxRequestSize = 32 << 20
+
+// Receiver serves remote-write requests, passing each decoded request to a callback.
+type Receiver struct {
+	onWrite func(req *WriteRequest)
+}
+
+// NewReceiver returns a receiver calling onWrite for each request. Since the sender
+// retries failed requests, onWrite is expected to have stored the samples on return.
+func NewReceiver(onWrite func(req *WriteRequest)) *Receiver {
+	return &Receiver{onWrite: onWrite}
+}
+
+func (rcv *Receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req, err := Decode(io.LimitReader(r.Body, maxRequestSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rcv.onWrite(req)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Decode reads a snappy-compressed request.
+func Decode(r io.Reader) (*WriteRequest, error) {
+	compressed, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRequest, err)
+	}
+	return Unmarshal(b)
+}
+
+// Encode compresses the protobuf encoding of the request, as expected by a receiver.
+func Encode(req *WriteRequest) []byte {
+	return snappy.Encode(nil, req.Marshal())
+}
@@ -0,0 +1,301 @@
+// Package remote implements the Prometheus remote-write protocol, whose requests
+// are snappy-compressed protobuf messages.
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/ostafen/proq/pkg/metric"
+)
+
+const metricNameLabel = "__name__"
+
+var ErrInvalidRequest = errors.New("invalid remote-write request")
+
+// WriteRequest is the message carried by a remote-write request. Native histograms
+// and exemplars are not supported, and are skipped when decoding.
+type WriteRequest struct {
+	Timeseries []TimeSeries
+	Metadata   []metric.Metadata
+}
+
+// TimeSeries holds the samples of a series, whose labels include its name.
+type TimeSeries struct {
+	Labels  []metric.Label
+	Samples []metric.Sample
+}
+
+// field numbers of the prometheus.WriteRequest message and of the messages it embeds.
+const (
+	writeRequestTimeseries = 1
+	writeRequestMetadata   = 3
+
+	timeSeriesLabels  = 1
+	timeSeriesSamples = 2
+
+	labelName  = 1
+	labelValue = 2
+
+	sampleValue     = 1
+	sampleTimestamp = 2
+
+	metadataType             = 1
+	metadataMetricFamilyName = 2
+	metadataHelp             = 4
+	metadataUnit             = 5
+)
+
+// metadataTypes lists the metric types in the order of the MetricMetadata.MetricType enum.
+var metadataTypes = []metric.MetricType{
+	metric.TypeUnknown,
+	metric.TypeCounter,
+	metric.TypeGauge,
+	metric.TypeHistogram,
+	metric.TypeGaugeHistogram,
+	metric.TypeSummary,
+	metric.TypeInfo,
+	metric.TypeStateSet,
+}
+
+// Samples returns the samples of the request, ordered by series and timestamp.
+func (req *WriteRequest) Samples() []metric.RawMetric {
+	var samples []metric.RawMetric
+	for _, ts := range req.Timeseries {
+		name, labels := splitName(ts.Labels)
+		for _, s := range ts.Samples {
+			samples = append(samples, metric.RawMetric{
+				Name:      name,
+				Labels:    slices.Clone(labels),
+				Value:     s.Value,
+				Timestamp: s.Timestamp,
+			})
+		}
+	}
+	return samples
+}
+
+func splitName(labels []metric.Label) (string, []metric.Label) {
+	var name string
+	out := make([]metric.Label, 0, len(labels))
+	for _, l := range labels {
+		if l.Name == metricNameLabel {
+			name = l.Value
+			continue
+		}
+		out = append(out, l)
+	}
+	return name, out
+}
+
+// MetadataByName returns the metadata of the request, keyed by family name.
+func (req *WriteRequest) MetadataByName() map[string]metric.Metadata {
+	md := make(map[string]metric.Metadata, len(req.Metadata))
+	for _, m := range req.Metadata {
+		md[m.Name] = m
+	}
+	return md
+}
+
+// Marshal encodes the request as a protobuf message, without compressing it.
+func (req *WriteRequest) Marshal() []byte {
+	var b []byte
+	for _, ts := range req.Timeseries {
+		b = protowire.AppendTag(b, writeRequestTimeseries, protowire.BytesType)
+		b = protowire.AppendBytes(b, marshalTimeSeries(&ts))
+	}
+
+	for _, md := range req.Metadata {
+		b = protowire.AppendTag(b, writeRequestMetadata, protowire.BytesType)
+		b = protowire.AppendBytes(b, marshalMetadata(&md))
+	}
+	return b
+}
+
+func marshalTimeSeries(ts *TimeSeries) []byte {
+	var b []byte
+	for _, l := range ts.Labels {
+		var lb []byte
+		lb = appendString(lb, labelName, l.Name)
+		lb = appendString(lb, labelValue, l.Value)
+
+		b = protowire.AppendTag(b, timeSeriesLabels, protowire.BytesType)
+		b = protowire.AppendBytes(b, lb)
+	}
+
+	for _, s := range ts.Samples {
+		var sb []byte
+		sb = protowire.AppendTag(sb, sampleValue, protowire.Fixed64Type)
+		sb = protowire.AppendFixed64(sb, math.Float64bits(s.Value))
+		sb = protowire.AppendTag(sb, sampleTimestamp, protowire.VarintType)
+		sb = protowire.AppendVarint(sb, uint64(s.Timestamp))
+
+		b = protowire.AppendTag(b, timeSeriesSamples, protowire.BytesType)
+		b = protowire.AppendBytes(b, sb)
+	}
+	return b
+}
+
+func marshalMetadata(md *metric.Metadata) []byte {
+	var b []byte
+	if idx := slices.Index(metadataTypes, md.Type); idx > 0 {
+		b = protowire.AppendTag(b, metadataType, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(idx))
+	}
+
+	b = appendString(b, metadataMetricFamilyName, md.Name)
+	b = appendString(b, metadataHelp, md.Help)
+	return appendString(b, metadataUnit, md.Unit)
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// Unmarshal decodes an uncompressed protobuf message.
+func Unmarshal(b []byte) (*WriteRequest, error) {
+	req := &WriteRequest{}
+	err := walkFields(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		switch {
+		case num == writeRequestTimeseries && typ == protowire.BytesType:
+			ts, err := unmarshalTimeSeries(v)
+			if err != nil {
+				return err
+			}
+			req.Timeseries = append(req.Timeseries, ts)
+		case num == writeRequestMetadata && typ == protowire.BytesType:
+			md, err := unmarshalMetadata(v)
+			if err != nil {
+				return err
+			}
+			req.Metadata = append(req.Metadata, md)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func unmarshalTimeSeries(b []byte) (TimeSeries, error) {
+	var ts TimeSeries
+	err := walkFields(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		switch {
+		case num == timeSeriesLabels && typ == protowire.BytesType:
+			l, err := unmarshalLabel(v)
+			if err != nil {
+				return err
+			}
+			ts.Labels = append(ts.Labels, l)
+		case num == timeSeriesSamples && typ == protowire.BytesType:
+			s, err := unmarshalSample(v)
+			if err != nil {
+				return err
+			}
+			ts.Samples = append(ts.Samples, s)
+		}
+		return nil
+	})
+	return ts, err
+}
+
+func unmarshalLabel(b []byte) (metric.Label, error) {
+	var l metric.Label
+	err := walkFields(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+
+		switch num {
+		case labelName:
+			l.Name = string(v)
+		case labelValue:
+			l.Value = string(v)
+		}
+		return nil
+	})
+	return l, err
+}
+
+func unmarshalSample(b []byte) (metric.Sample, error) {
+	var s metric.Sample
+	err := walkFields(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		switch {
+		case num == sampleValue && typ == protowire.Fixed64Type:
+			bits, _ := protowire.ConsumeFixed64(v)
+			s.Value = math.Float64frombits(bits)
+		case num == sampleTimestamp && typ == protowire.VarintType:
+			ts, _ := protowire.ConsumeVarint(v)
+			s.Timestamp = int64(ts)
+		}
+		return nil
+	})
+	return s, err
+}
+
+func unmarshalMetadata(b []byte) (metric.Metadata, error) {
+	md := metric.Metadata{Type: metric.TypeUnknown}
+	err := walkFields(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if num == metadataType && typ == protowire.VarintType {
+			t, _ := protowire.ConsumeVarint(v)
+			if t < uint64(len(metadataTypes)) {
+				md.Type = metadataTypes[t]
+			}
+			return nil
+		}
+
+		if typ != protowire.BytesType {
+			return nil
+		}
+
+		switch num {
+		case metadataMetricFamilyName:
+			md.Name = string(v)
+		case metadataHelp:
+			md.Help = string(v)
+		case metadataUnit:
+			md.Unit = string(v)
+		}
+		return nil
+	})
+	return md, err
+}
+
+// walkFields calls onField for each field of a message. The value of length-delimited
+// fields is passed without its length prefix, while the value of the others is passed
+// in its wire encoding.
+func walkFields(b []byte, onField func(num protowire.Number, typ protowire.Type, v []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return fmt.Errorf("%w: %w", ErrInvalidRequest, protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			return fmt.Errorf("%w: %w", ErrInvalidRequest, protowire.ParseError(n))
+		}
+
+		v := b[:n]
+		if typ == protowire.BytesType {
+			v, _ = protowire.ConsumeBytes(v)
+		}
+
+		if err := onField(num, typ, v); err != nil {
+			return err
+		}
+		b = b[n:]
+	}
+	return nil
+}
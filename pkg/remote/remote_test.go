@@ -0,0 +1,136 @@
+package remote
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ostafen/proq/pkg/metric"
+)
+
+func testRequest() *WriteRequest {
+	return &WriteRequest{
+		Timeseries: []TimeSeries{
+			{
+				Labels: []metric.Label{
+					{Name: "__name__", Value: "jobs_processed_total"},
+					{Name: "job", Value: "batch"},
+				},
+				Samples: []metric.Sample{{Timestamp: 1000, Value: 1}, {Timestamp: 2000, Value: 3.5}},
+			},
+			{
+				Labels:  []metric.Label{{Name: "__name__", Value: "offset"}},
+				Samples: []metric.Sample{{Timestamp: -1000, Value: -2}},
+			},
+		},
+		Metadata: []metric.Metadata{
+			{Name: "jobs_processed", Type: metric.TypeCounter, Help: "Processed jobs."},
+		},
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	req := testRequest()
+
+	decoded, err := Unmarshal(req.Marshal())
+	require.NoError(t, err)
+	require.Equal(t, req, decoded)
+
+	_, err = Unmarshal([]byte{0x0a, 0x05, 0x01})
+	require.ErrorIs(t, err, ErrInvalidRequest)
+}
+
+func TestSamples(t *testing.T) {
+	require.Equal(t, []metric.RawMetric{
+		{Name: "jobs_processed_total", Labels: []metric.Label{{Name: "job", Value: "batch"}}, Value: 1, Timestamp: 1000},
+		{Name: "jobs_processed_total", Labels: []metric.Label{{Name: "job", Value: "batch"}}, Value: 3.5, Timestamp: 2000},
+		{Name: "offset", Labels: []metric.Label{}, Value: -2, Timestamp: -1000},
+	}, testRequest().Samples())
+}
+
+func TestReceiver(t *testing.T) {
+	var received *WriteRequest
+	rcv := NewReceiver(func(req *WriteRequest) {
+		received = req
+	})
+
+	rec := httptest.NewRecorder()
+	rcv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/write", bytes.NewReader(Encode(testRequest()))))
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.Equal(t, testRequest(), received)
+
+	rec = httptest.NewRecorder()
+	rcv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/write", bytes.NewReader([]byte("not snappy"))))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	rcv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/write", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestQueue(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []metric.RawMetric
+		failures = 2
+	)
+
+	rcv := NewReceiver(func(req *WriteRequest) {
+		received = append(received, req.Samples()...)
+	})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if failures > 0 {
+			failures--
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		rcv.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	cfg := DefaultQueueConfig
+	cfg.Capacity = 3
+	cfg.MaxBatchSize = 2
+	cfg.MinBackoff = time.Millisecond
+
+	q := NewQueue(srv.URL, cfg)
+
+	samples := testRequest().Samples()
+	q.Enqueue(samples)
+	q.Close()
+
+	stats := q.Stats()
+	require.Equal(t, uint64(len(samples)), stats.Sent+stats.Dropped)
+	require.Equal(t, uint64(0), stats.Failed)
+	require.Equal(t, uint64(2), stats.Retries)
+	require.Error(t, stats.LastError)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, samples[:stats.Sent], received)
+}
+
+func TestQueueRejected(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "out of order sample", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	q := NewQueue(srv.URL, DefaultQueueConfig)
+	q.Enqueue(testRequest().Samples())
+	q.Close()
+
+	stats := q.Stats()
+	require.Equal(t, uint64(3), stats.Failed)
+	require.Equal(t, uint64(0), stats.Retries)
+	require.ErrorContains(t, stats.LastError, "out of order sample")
+}
@@ -0,0 +1,280 @@
+// Package replay loads expositions captured in the past, so that they can be
+// played back as if they were being scraped.
+package replay
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ostafen/proq/pkg/metric"
+	"github.com/ostafen/proq/pkg/record"
+	"github.com/ostafen/proq/pkg/scrape"
+)
+
+// Snapshot is the result of a scrape made at a point in time.
+type Snapshot struct {
+	Timestamp time.Time
+	Result    *scrape.Result
+}
+
+// Load reads the snapshots stored at path, sorted by timestamp. The path can be:
+//   - a file written by "proq record";
+//   - a directory, or a tar archive, of exposition dumps, one per file;
+//   - a single exposition dump.
+//
+// The time a dump was taken is parsed from its file name, when it contains a unix timestamp
+// or a date such as 2006-01-02T15:04:05, and defaults to its modification time otherwise.
+func Load(path string) ([]Snapshot, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []Snapshot
+	switch {
+	case info.IsDir():
+		snapshots, err = loadDir(path)
+	case isTar(path):
+		snapshots, err = loadTar(path)
+	default:
+		snapshots, err = loadFile(path, info)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("no snapshot found in \"%s\"", path)
+	}
+
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].Timestamp.Before(snapshots[j].Timestamp)
+	})
+	return snapshots, nil
+}
+
+func isTar(path string) bool {
+	for _, ext := range []string{".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(path, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func loadDir(dir string) ([]Snapshot, error) {
+	var snapshots []Snapshot
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() && path != dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		s, err := parseDump(path, data, snapshotTime(d.Name(), info.ModTime()))
+		if err != nil {
+			return err
+		}
+		snapshots = append(snapshots, s)
+		return nil
+	})
+	return snapshots, err
+}
+
+func loadTar(path string) ([]Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if !strings.HasSuffix(path, ".tar") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	var snapshots []Snapshot
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return snapshots, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := filepath.Base(hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+
+		s, err := parseDump(hdr.Name, data, snapshotTime(name, hdr.ModTime))
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, s)
+	}
+}
+
+func loadFile(path string, info fs.FileInfo) ([]Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := record.NewReader(f)
+	if errors.Is(err, record.ErrInvalidRecord) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		s, err := parseDump(path, data, snapshotTime(info.Name(), info.ModTime()))
+		if err != nil {
+			return nil, err
+		}
+		return []Snapshot{s}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return loadRecord(r)
+}
+
+// loadRecord converts each batch of a record file to a snapshot. Since the recorded
+// samples already carry the labels of their target, no target is attached to them.
+func loadRecord(r *record.Reader) ([]Snapshot, error) {
+	var snapshots []Snapshot
+	for {
+		b, err := r.Next()
+		// a recording which was interrupted may end with a partial entry.
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return snapshots, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		ts := time.UnixMilli(b.Timestamp)
+		snapshots = append(snapshots, Snapshot{
+			Timestamp: ts,
+			Result:    scrape.NewResult(scrape.Target{}, b.RawMetrics(), b.Metadata(), ts),
+		})
+	}
+}
+
+// DefaultInstance labels the dumps which are not in a directory, such as
+// the ones at the top level of an archive.
+const DefaultInstance = "replay"
+
+// parseDump parses an exposition, as if it was scraped at time ts. Samples are labelled with the
+// name of the directory containing the dump as instance, so that dumps of different instances
+// can be told apart.
+func parseDump(path string, data []byte, ts time.Time) (Snapshot, error) {
+	target := scrape.Target{
+		Job:      scrape.DefaultJob,
+		Instance: filepath.Base(filepath.Dir(path)),
+	}
+
+	if target.Instance == "." || target.Instance == string(filepath.Separator) {
+		target.Instance = DefaultInstance
+	}
+
+	res, err := scrape.Parse(target, dumpContentType(path, data), bytes.NewReader(data), ts)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return Snapshot{Timestamp: ts, Result: res}, nil
+}
+
+// dumpContentType guesses the format of an exposition, since dumps don't carry their content type.
+func dumpContentType(path string, data []byte) string {
+	switch {
+	case filepath.Ext(path) == ".pb":
+		return metric.ContentTypeProtobuf + ";proto=io.prometheus.client.MetricFamily;encoding=delimited"
+	case bytes.HasSuffix(bytes.TrimSpace(data), []byte("# EOF")):
+		return metric.ContentTypeOpenMetrics
+	}
+	return metric.ContentTypeText
+}
+
+var (
+	unixTimeRegexp = regexp.MustCompile(`\d{10}(\d{3})?`)
+	dateTimeRegexp = regexp.MustCompile(`\d{4}-?\d{2}-?\d{2}[T_-]?\d{2}[:-]?\d{2}[:-]?\d{2}`)
+)
+
+// snapshotTime parses the time a dump was taken from its file name, falling back to modTime.
+func snapshotTime(name string, modTime time.Time) time.Time {
+	if s := dateTimeRegexp.FindString(name); s != "" {
+		digits := strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, s)
+
+		if t, err := time.ParseInLocation("20060102150405", digits, time.Local); err == nil {
+			return t
+		}
+	}
+
+	if s := unixTimeRegexp.FindString(name); s != "" {
+		n, _ := strconv.ParseInt(s, 10, 64)
+		if len(s) == 13 {
+			return time.UnixMilli(n)
+		}
+		return time.Unix(n, 0)
+	}
+	return modTime
+}
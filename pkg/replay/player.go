@@ -0,0 +1,119 @@
+package replay
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Player plays snapshots back on a virtual clock which, unless paused,
+// advances Speed times as fast as the wall clock.
+type Player struct {
+	snapshots []Snapshot
+
+	// next is the index of the first snapshot which is still due.
+	next int
+	now  time.Time
+
+	speed  float64
+	paused bool
+}
+
+// NewPlayer creates a player positioned at the first of the snapshots,
+// which must be sorted by timestamp.
+func NewPlayer(snapshots []Snapshot) *Player {
+	return &Player{
+		snapshots: snapshots,
+		now:       snapshots[0].Timestamp,
+		speed:     1,
+	}
+}
+
+// Now returns the time of the virtual clock.
+func (p *Player) Now() time.Time {
+	return p.now
+}
+
+func (p *Player) Start() time.Time {
+	return p.snapshots[0].Timestamp
+}
+
+func (p *Player) End() time.Time {
+	return p.snapshots[len(p.snapshots)-1].Timestamp
+}
+
+func (p *Player) Paused() bool {
+	return p.paused
+}
+
+func (p *Player) TogglePause() {
+	p.paused = !p.paused
+}
+
+func (p *Player) Speed() float64 {
+	return p.speed
+}
+
+func (p *Player) SetSpeed(speed float64) error {
+	if speed <= 0 {
+		return fmt.Errorf("invalid speed: %g", speed)
+	}
+	p.speed = speed
+	return nil
+}
+
+// Advance moves the clock forward by d, scaled by the speed, and returns the snapshots
+// which became due. The player is paused once the last snapshot has been played.
+func (p *Player) Advance(d time.Duration) []Snapshot {
+	if !p.paused {
+		p.now = p.now.Add(time.Duration(float64(d) * p.speed))
+	}
+
+	due := p.due()
+	if p.next == len(p.snapshots) {
+		p.now = p.End()
+		p.paused = true
+	}
+	return due
+}
+
+// Step moves the clock to the next snapshot, and returns it.
+func (p *Player) Step() []Snapshot {
+	if p.next == len(p.snapshots) {
+		return nil
+	}
+
+	p.now = p.snapshots[p.next].Timestamp
+	return p.due()
+}
+
+// Seek moves the clock to t, within the time range of the snapshots, and returns the snapshots
+// taken over the window before it. They are meant to be loaded in place of the ones played so far.
+func (p *Player) Seek(t time.Time, window time.Duration) []Snapshot {
+	if t.Before(p.Start()) {
+		t = p.Start()
+	}
+
+	if t.After(p.End()) {
+		t = p.End()
+	}
+
+	p.now = t
+	p.next = sort.Search(len(p.snapshots), func(i int) bool {
+		return p.snapshots[i].Timestamp.After(t)
+	})
+
+	from := t.Add(-window)
+	first := sort.Search(p.next, func(i int) bool {
+		return !p.snapshots[i].Timestamp.Before(from)
+	})
+	return p.snapshots[first:p.next]
+}
+
+func (p *Player) due() []Snapshot {
+	first := p.next
+	for p.next < len(p.snapshots) && !p.snapshots[p.next].Timestamp.After(p.now) {
+		p.next++
+	}
+	return p.snapshots[first:p.next]
+}
@@ -0,0 +1,162 @@
+package replay
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ostafen/proq/pkg/metric"
+	"github.com/ostafen/proq/pkg/record"
+)
+
+func TestLoadDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "node1")
+	require.NoError(t, os.Mkdir(dir, 0o755))
+
+	files := map[string]string{
+		"metrics-2024-03-01T10:00:05.txt": "requests_total 2\n",
+		"metrics-2024-03-01T10:00:00.txt": "requests_total 1\n",
+		".hidden":                         "requests_total 0\n",
+	}
+	for name, data := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644))
+	}
+
+	snapshots, err := Load(dir)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.Local)
+	require.Equal(t, start, snapshots[0].Timestamp)
+	require.Equal(t, start.Add(5*time.Second), snapshots[1].Timestamp)
+
+	m := snapshots[1].Result.Metrics[0]
+	require.Equal(t, 2.0, m.Value)
+	require.Equal(t, start.Add(5*time.Second).UnixMilli(), m.Timestamp)
+	require.Equal(t, "node1", m.Find("instance"))
+}
+
+func TestLoadTar(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dumps.tar.gz")
+
+	f, err := os.Create(path)
+	require.NoError(t, err)
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, name := range []string{"1709287210.prom", "1709287200.prom"} {
+		data := []byte("requests_total 1\n")
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: "dumps/" + name, Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	require.NoError(t, f.Close())
+
+	snapshots, err := Load(path)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	require.Equal(t, time.Unix(1709287200, 0), snapshots[0].Timestamp)
+	require.Equal(t, time.Unix(1709287210, 0), snapshots[1].Timestamp)
+}
+
+func TestLoadRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.rec")
+
+	w, err := record.Open(path)
+	require.NoError(t, err)
+
+	h := metric.Histogram{
+		Name:      "latency_seconds",
+		Labels:    []metric.Label{{Name: "job", Value: "api"}},
+		Bins:      []metric.Bin{{Value: 0.1, Count: 1}, {Value: 1, Count: 3}, {Value: math.Inf(1), Count: 4}},
+		Sum:       2.5,
+		Count:     4,
+		Timestamp: 1000,
+	}
+
+	// gauge histograms expose their count and sum as _gcount and _gsum.
+	gh := metric.Histogram{
+		Name:      "queue_size",
+		Labels:    []metric.Label{{Name: "job", Value: "api"}},
+		Bins:      []metric.Bin{{Value: 10, Count: 2}, {Value: math.Inf(1), Count: 5}},
+		Sum:       40,
+		Count:     5,
+		Timestamp: 1000,
+	}
+
+	series := h.Series()
+	for _, m := range gh.Series() {
+		m.Name = strings.Replace(m.Name, "_count", "_gcount", 1)
+		m.Name = strings.Replace(m.Name, "_sum", "_gsum", 1)
+		series = append(series, m)
+	}
+
+	metadata := map[string]metric.Metadata{
+		"latency_seconds": {Name: "latency_seconds", Type: metric.TypeHistogram},
+		"queue_size":      {Name: "queue_size", Type: metric.TypeGaugeHistogram},
+	}
+	require.NoError(t, w.Append(series, metadata))
+	require.NoError(t, w.Close())
+
+	snapshots, err := Load(path)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+
+	res := snapshots[0].Result
+	require.Empty(t, res.Metrics)
+	require.Len(t, res.Histograms, 2)
+	require.Equal(t, metadata, res.Metadata)
+
+	for _, want := range []metric.Histogram{h, gh} {
+		mk := metric.MetricKey{Name: want.Name, Labels: want.Labels}
+
+		got, ok := res.Histograms[mk.String()]
+		require.True(t, ok, mk.String())
+		require.Equal(t, want.Bins, got.Bins)
+		require.Equal(t, want.Labels, got.Labels)
+		require.Equal(t, want.Count, got.Count)
+		require.Equal(t, want.Sum, got.Sum)
+	}
+}
+
+func TestPlayer(t *testing.T) {
+	start := time.Unix(1000, 0)
+
+	var snapshots []Snapshot
+	for i := range 5 {
+		snapshots = append(snapshots, Snapshot{Timestamp: start.Add(time.Duration(i) * 10 * time.Second)})
+	}
+
+	p := NewPlayer(snapshots)
+	require.Len(t, p.Advance(0), 1)
+
+	require.NoError(t, p.SetSpeed(2))
+	require.Len(t, p.Advance(10*time.Second), 2)
+	require.Equal(t, start.Add(20*time.Second), p.Now())
+
+	p.TogglePause()
+	require.Empty(t, p.Advance(time.Minute))
+
+	due := p.Step()
+	require.Len(t, due, 1)
+	require.Equal(t, start.Add(30*time.Second), p.Now())
+
+	due = p.Seek(start.Add(25*time.Second), 15*time.Second)
+	require.Equal(t, []Snapshot{snapshots[1], snapshots[2]}, due)
+
+	p.TogglePause()
+	require.Len(t, p.Advance(time.Hour), 2)
+	require.Equal(t, p.End(), p.Now())
+	require.True(t, p.Paused())
+
+	require.Error(t, p.SetSpeed(0))
+}
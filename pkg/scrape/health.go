@@ -0,0 +1,70 @@
+package scrape
+
+import (
+	"github.com/ostafen/proq/pkg/metric"
+)
+
+// series recorded for each scrape, as Prometheus does.
+const (
+	UpMetric             = "up"
+	DurationMetric       = "scrape_duration_seconds"
+	SamplesScrapedMetric = "scrape_samples_scraped"
+	ParseErrorsMetric    = "scrape_parse_errors"
+)
+
+// HealthMetadata describes the series returned by Result.Health.
+var HealthMetadata = map[string]metric.Metadata{
+	UpMetric: {
+		Name: UpMetric,
+		Type: metric.TypeGauge,
+		Help: "1 if the target was scraped successfully, 0 otherwise.",
+	},
+	DurationMetric: {
+		Name: DurationMetric,
+		Type: metric.TypeGauge,
+		Help: "Duration of the scrape.",
+		Unit: "seconds",
+	},
+	SamplesScrapedMetric: {
+		Name: SamplesScrapedMetric,
+		Type: metric.TypeGauge,
+		Help: "Number of samples exposed by the target.",
+	},
+	ParseErrorsMetric: {
+		Name: ParseErrorsMetric,
+		Type: metric.TypeGauge,
+		Help: "Number of malformed lines skipped by the scrape.",
+	},
+}
+
+// Health returns the series describing the scrape, labelled with the target labels.
+func (res *Result) Health() []metric.RawMetric {
+	up, samples := 0.0, 0
+	if res.Err == nil {
+		up = 1
+		samples = len(res.Samples()) + len(res.Natives)
+	}
+
+	values := []struct {
+		name  string
+		value float64
+	}{
+		{UpMetric, up},
+		{DurationMetric, res.Duration.Seconds()},
+		{SamplesScrapedMetric, float64(samples)},
+		{ParseErrorsMetric, float64(len(res.ParseErrors))},
+	}
+
+	ts := res.Timestamp.UnixMilli()
+
+	out := make([]metric.RawMetric, len(values))
+	for i, v := range values {
+		out[i] = metric.RawMetric{
+			Name:      v.name,
+			Labels:    res.Target.Labels(),
+			Value:     v.value,
+			Timestamp: ts,
+		}
+	}
+	return out
+}
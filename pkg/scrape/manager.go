@@ -0,0 +1,85 @@
+package scrape
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Manager scrapes each target on its own schedule, in the background, and delivers
+// the results over a channel, so that a slow target doesn't delay the others.
+type Manager struct {
+	targets  []Target
+	interval time.Duration
+
+	results chan *Result
+}
+
+// NewManager returns a manager scraping the targets at the given interval.
+func NewManager(targets []Target, interval time.Duration) *Manager {
+	return &Manager{
+		targets:  targets,
+		interval: interval,
+		results:  make(chan *Result, len(targets)),
+	}
+}
+
+// Results delivers the result of each scrape, including the failed ones.
+func (m *Manager) Results() <-chan *Result {
+	return m.results
+}
+
+// Run scrapes the targets until the context is cancelled, which also aborts
+// the scrapes in progress. It blocks until all the scrapes are stopped.
+func (m *Manager) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, t := range m.targets {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			m.scrapeLoop(ctx, t)
+		}()
+	}
+	wg.Wait()
+}
+
+// scrapeLoop scrapes a target periodically. The first scrape is delayed by a random
+// fraction of the interval, which spreads the scrapes of the targets over time.
+func (m *Manager) scrapeLoop(ctx context.Context, t Target) {
+	// the timeout can't exceed the interval, so that scrapes never overlap.
+	t.Timeout = min(t.ScrapeTimeout(), m.interval)
+
+	jitter := time.NewTimer(rand.N(m.interval))
+	defer jitter.Stop()
+
+	select {
+	case <-jitter.C:
+	case <-ctx.Done():
+		return
+	}
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		res := scrapeTarget(ctx, t)
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		select {
+		case m.results <- res:
+		case <-ctx.Done():
+			return
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package scrape
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ostafen/proq/pkg/metric"
+)
+
+// Result holds the series exposed by a target, with histograms
+// and summaries already grouped.
+type Result struct {
+	Target Target
+
+	// Timestamp is the time the scrape started, which is assigned
+	// to the samples not carrying their own timestamp.
+	Timestamp time.Time
+
+	Metrics    []metric.RawMetric
+	Histograms map[string]metric.Histogram
+	Natives    map[string]metric.NativeHistogram
+	Summaries  map[string]metric.Summary
+	Metadata   map[string]metric.Metadata
+
+	// ParseErrors holds the malformed lines which have been skipped.
+	ParseErrors []error
+
+	// Duration is how long the scrape took, including parsing.
+	Duration time.Duration
+
+	// Err is set by ScrapeAll and Manager when the target could not be scraped.
+	Err error
+}
+
+// Samples returns the samples of the result, splitting histograms and summaries back
+// into their series. Native histograms, which have no series representation, are left out.
+func (res *Result) Samples() []metric.RawMetric {
+	samples := slices.Clone(res.Metrics)
+	for _, k := range slices.Sorted(maps.Keys(res.Histograms)) {
+		h := res.Histograms[k]
+		samples = append(samples, h.Series()...)
+	}
+
+	for _, k := range slices.Sorted(maps.Keys(res.Summaries)) {
+		s := res.Summaries[k]
+		samples = append(samples, s.Series()...)
+	}
+	return samples
+}
+
+// Scrape fetches and parses the metrics exposed by the target, negotiating the exposition
+// format through the Accept header. The scrape is given up after the timeout of the target.
+func Scrape(ctx context.Context, t Target) (*Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.ScrapeTimeout())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", metric.AcceptHeader)
+	req.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", strconv.FormatFloat(t.ScrapeTimeout().Seconds(), 'f', -1, 64))
+
+	start := time.Now()
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching metrics: %w", err)
+	}
+	defer resp.Body.Close()
+
+	return Parse(t, resp.Header.Get("Content-Type"), resp.Body, start)
+}
+
+// Parse reads an exposition of the given content type, as if it was scraped from the target at time ts.
+func Parse(t Target, contentType string, r io.Reader, ts time.Time) (*Result, error) {
+	res := &Result{
+		Target:    t,
+		Timestamp: ts,
+	}
+
+	rawMetrics := make([]metric.RawMetric, 0, 100)
+
+	p := metric.NewParser(contentType, r)
+	for {
+		m, err := p.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		var perr *metric.ParseError
+		if errors.As(err, &perr) {
+			res.ParseErrors = append(res.ParseErrors, err)
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("error reading metrics: %w", err)
+		}
+
+		if m.Timestamp == 0 {
+			m.Timestamp = ts.UnixMilli()
+		}
+
+		m.Labels = t.attachLabels(m.Labels)
+		rawMetrics = append(rawMetrics, m)
+	}
+
+	res.group(rawMetrics, p.Metadata())
+
+	if np, ok := p.(metric.NativeHistogramParser); ok {
+		res.Natives = t.attachNativeLabels(np.NativeHistograms(), ts)
+	}
+	return res, nil
+}
+
+// NewResult builds the result of a scrape from the samples of an exposition,
+// which are expected to already carry their timestamp and target labels.
+func NewResult(t Target, metrics []metric.RawMetric, metadata map[string]metric.Metadata, ts time.Time) *Result {
+	res := &Result{
+		Target:    t,
+		Timestamp: ts,
+	}
+	res.group(metrics, metadata)
+	return res
+}
+
+// group sets the samples of the result, grouping histograms and summaries.
+func (res *Result) group(metrics []metric.RawMetric, metadata map[string]metric.Metadata) {
+	histograms, rem := metric.ParseHistogram(metrics, metadata)
+	summaries, rem := metric.ParseSummary(rem, metadata)
+
+	res.Metrics = rem
+	res.Histograms = histograms
+	res.Summaries = summaries
+	res.Metadata = metadata
+}
+
+func (t *Target) attachNativeLabels(hs map[string]metric.NativeHistogram, ts time.Time) map[string]metric.NativeHistogram {
+	out := make(map[string]metric.NativeHistogram, len(hs))
+	for _, h := range hs {
+		if h.Timestamp == 0 {
+			h.Timestamp = ts.UnixMilli()
+		}
+
+		h.Labels = t.attachLabels(h.Labels)
+		metric.SortLabels(h.Labels)
+
+		mk := metric.MetricKey{
+			Name:   h.Name,
+			Labels: h.Labels,
+		}
+		out[mk.String()] = h
+	}
+	return out
+}
+
+// scrapeTarget scrapes a target, returning a result holding the error of a failed scrape.
+func scrapeTarget(ctx context.Context, t Target) *Result {
+	start := time.Now()
+
+	res, err := Scrape(ctx, t)
+	if err != nil {
+		res = &Result{Target: t, Timestamp: start, Err: err}
+	}
+	res.Duration = time.Since(start)
+	return res
+}
+
+// ScrapeAll scrapes the targets concurrently. Results are returned
+// in the same order of targets.
+func ScrapeAll(ctx context.Context, targets []Target) []*Result {
+	results := make([]*Result, len(targets))
+
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			results[i] = scrapeTarget(ctx, t)
+		}()
+	}
+
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,148 @@
+package scrape
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ostafen/proq/pkg/metric"
+)
+
+func TestParseTarget(t *testing.T) {
+	target, err := ParseTarget("http://localhost:9100/metrics?collect[]=cpu")
+	require.NoError(t, err)
+	require.Equal(t, Target{
+		URL:      "http://localhost:9100/metrics?collect[]=cpu",
+		Job:      DefaultJob,
+		Instance: "localhost:9100",
+	}, target)
+
+	target, err = ParseTarget("api=https://10.0.0.1:8443/metrics")
+	require.NoError(t, err)
+	require.Equal(t, "api", target.Job)
+	require.Equal(t, "10.0.0.1:8443", target.Instance)
+
+	target, err = ParseTarget("http://localhost:9100/metrics scrape_timeout=2s")
+	require.NoError(t, err)
+	require.Equal(t, 2*time.Second, target.Timeout)
+
+	_, err = ParseTarget("localhost:9100")
+	require.Error(t, err)
+
+	_, err = ParseTarget("http://localhost:9100/metrics interval=2s")
+	require.Error(t, err)
+}
+
+func TestLoadTargets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets")
+	err := os.WriteFile(path, []byte("# workers\nworker=http://localhost:8081/metrics\n\nhttp://localhost:8082/metrics\n"), 0o644)
+	require.NoError(t, err)
+
+	targets, err := LoadTargets(path)
+	require.NoError(t, err)
+	require.Len(t, targets, 2)
+	require.Equal(t, "worker", targets[0].Job)
+	require.Equal(t, "localhost:8082", targets[1].Instance)
+}
+
+func TestScrapeAll(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, metric.AcceptHeader, r.Header.Get("Accept"))
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.Write([]byte(`up{job="exporter"} 1
+broken{ 1
+`))
+	}))
+	defer srv.Close()
+
+	target, err := ParseTarget("app=" + srv.URL)
+	require.NoError(t, err)
+
+	results := ScrapeAll(context.Background(), []Target{target, {URL: "http://127.0.0.1:0/metrics"}})
+	require.Len(t, results, 2)
+
+	res := results[0]
+	require.NoError(t, res.Err)
+	require.Len(t, res.ParseErrors, 1)
+	require.Equal(t, []metric.RawMetric{
+		{
+			Name: "up",
+			Labels: []metric.Label{
+				{Name: "exported_job", Value: "exporter"},
+				{Name: JobLabel, Value: "app"},
+				{Name: InstanceLabel, Value: target.Instance},
+			},
+			Value:     1,
+			Timestamp: res.Timestamp.UnixMilli(),
+		},
+	}, res.Metrics)
+
+	require.Error(t, results[1].Err)
+
+	health := res.Health()
+	require.Len(t, health, 4)
+	require.Equal(t, UpMetric, health[0].Name)
+	require.Equal(t, 1.0, health[0].Value)
+	require.Equal(t, target.Labels(), health[0].Labels)
+	require.Equal(t, 1.0, health[2].Value)
+	require.Equal(t, 1.0, health[3].Value)
+
+	health = results[1].Health()
+	require.Equal(t, 0.0, health[0].Value)
+	require.Equal(t, 0.0, health[2].Value)
+}
+
+func TestManager(t *testing.T) {
+	hang := make(chan struct{})
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-hang
+	}))
+	defer slow.Close()
+	defer close(hang)
+
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("up 1\n"))
+	}))
+	defer fast.Close()
+
+	targets := []Target{
+		{URL: slow.URL, Job: "slow", Timeout: 50 * time.Millisecond},
+		{URL: fast.URL, Job: "fast"},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	mgr := NewManager(targets, 100*time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mgr.Run(ctx)
+	}()
+
+	results := make(map[string][]*Result)
+	for len(results["slow"]) < 2 || len(results["fast"]) < 2 {
+		res := <-mgr.Results()
+		results[res.Target.Job] = append(results[res.Target.Job], res)
+	}
+
+	for _, res := range results["slow"] {
+		require.ErrorIs(t, res.Err, context.DeadlineExceeded)
+	}
+
+	for _, res := range results["fast"] {
+		require.NoError(t, res.Err)
+		require.Len(t, res.Metrics, 1)
+	}
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,142 @@
+package scrape
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ostafen/proq/pkg/metric"
+)
+
+const (
+	JobLabel      = "job"
+	InstanceLabel = "instance"
+
+	DefaultJob = "proq"
+
+	// DefaultTimeout bounds the scrapes of the targets which don't set their own timeout.
+	DefaultTimeout = 10 * time.Second
+
+	timeoutOption = "scrape_timeout"
+)
+
+// Target is an endpoint exposing metrics. Every scraped series
+// is labelled with the job and instance of its target.
+type Target struct {
+	URL      string
+	Job      string
+	Instance string
+
+	// Timeout bounds each scrape of the target. When zero, DefaultTimeout is used.
+	Timeout time.Duration
+}
+
+// ParseTarget parses a target in the "[job=]url [scrape_timeout=<duration>]" form.
+// When the job is omitted, DefaultJob is used. The instance is the host and port of the url.
+func ParseTarget(spec string) (Target, error) {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 {
+		return Target{}, fmt.Errorf("empty target")
+	}
+
+	t, err := parseTargetURL(fields[0])
+	if err != nil {
+		return Target{}, err
+	}
+
+	for _, opt := range fields[1:] {
+		name, value, _ := strings.Cut(opt, "=")
+		if name != timeoutOption {
+			return Target{}, fmt.Errorf("invalid target \"%s\": unknown option \"%s\"", spec, name)
+		}
+
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return Target{}, fmt.Errorf("invalid target \"%s\": invalid %s \"%s\"", spec, timeoutOption, value)
+		}
+		t.Timeout = d
+	}
+	return t, nil
+}
+
+func parseTargetURL(spec string) (Target, error) {
+	job := DefaultJob
+
+	rawURL := spec
+	if idx := strings.Index(spec, "="); idx > 0 && !strings.Contains(spec[:idx], "://") {
+		job, rawURL = spec[:idx], spec[idx+1:]
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Target{}, fmt.Errorf("invalid target \"%s\": %w", spec, err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return Target{}, fmt.Errorf("invalid target \"%s\": unsupported scheme \"%s\"", spec, u.Scheme)
+	}
+
+	return Target{
+		URL:      rawURL,
+		Job:      job,
+		Instance: u.Host,
+	}, nil
+}
+
+// ScrapeTimeout returns the timeout of the scrapes of the target.
+func (t *Target) ScrapeTimeout() time.Duration {
+	if t.Timeout > 0 {
+		return t.Timeout
+	}
+	return DefaultTimeout
+}
+
+// LoadTargets reads a list of targets from a file, one per line.
+// Blank lines and lines starting with '#' are skipped.
+func LoadTargets(path string) ([]Target, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var targets []Target
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		t, err := ParseTarget(line)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+	return targets, sc.Err()
+}
+
+func (t *Target) Labels() []metric.Label {
+	return []metric.Label{
+		{Name: JobLabel, Value: t.Job},
+		{Name: InstanceLabel, Value: t.Instance},
+	}
+}
+
+// attachLabels adds the target labels to a series. Conflicting labels
+// exposed by the target are preserved with an "exported_" prefix.
+func (t *Target) attachLabels(labels []metric.Label) []metric.Label {
+	out := make([]metric.Label, 0, len(labels)+2)
+	for _, l := range labels {
+		if l.Name == JobLabel || l.Name == InstanceLabel {
+			l.Name = "exported_" + l.Name
+		}
+		out = append(out, l)
+	}
+	return append(out, t.Labels()...)
+}
@@ -2,28 +2,73 @@ package store
 
 import (
 	"maps"
+	"slices"
 	"sort"
+	"time"
 
 	"github.com/ostafen/proq/pkg/metric"
 )
 
+// StreamSample is a sample delivered to a bound stream,
+// tagged with the plot line the stream feeds.
+type StreamSample struct {
+	Line int
+	metric.Sample
+}
+
 type RingBuffer struct {
-	next   int
-	values []float64
-	n      uint64
+	key metric.MetricKey
 
-	ch    chan float64
+	next    int
+	samples []metric.Sample
+	n       uint64
+
+	ch    chan StreamSample
+	line  int
 	bindN uint64
 }
 
-func (buf *RingBuffer) Add(v float64) {
-	buf.values[buf.next] = v
-	buf.next = (buf.next + 1) % len(buf.values)
+// Add appends a sample to the buffer. Samples which are not newer than the last one,
+// such as a repeated exposition timestamp, are discarded.
+func (buf *RingBuffer) Add(s metric.Sample) {
+	if last, ok := buf.last(); ok && s.Timestamp <= last.Timestamp {
+		return
+	}
+
+	buf.samples[buf.next] = s
+	buf.next = (buf.next + 1) % len(buf.samples)
 	buf.n++
 
 	if buf.ch != nil {
-		buf.ch <- v
+		buf.ch <- StreamSample{Line: buf.line, Sample: s}
+	}
+}
+
+func (buf *RingBuffer) last() (metric.Sample, bool) {
+	if buf.n == 0 {
+		return metric.Sample{}, false
 	}
+	return buf.samples[(buf.next-1+len(buf.samples))%len(buf.samples)], true
+}
+
+// histogramHistory keeps the latest snapshots of a histogram.
+type histogramHistory struct {
+	next      int
+	n         int
+	snapshots []metric.Histogram
+}
+
+func (hh *histogramHistory) add(h metric.Histogram) {
+	if hh.n > 0 {
+		last := hh.snapshots[(hh.next-1+len(hh.snapshots))%len(hh.snapshots)]
+		if h.Timestamp <= last.Timestamp {
+			return
+		}
+	}
+
+	hh.snapshots[hh.next] = h
+	hh.next = (hh.next + 1) % len(hh.snapshots)
+	hh.n = min(hh.n+1, len(hh.snapshots))
 }
 
 type MetricID uint32
@@ -37,24 +82,87 @@ type MetricStore struct {
 	metrics map[MetricID]*RingBuffer
 
 	histograms map[string]metric.Histogram
+	history    map[string]*histogramHistory
+	natives    map[string]metric.NativeHistogram
+	summaries  map[string]metric.Summary
+	metadata   map[string]metric.Metadata
 }
 
 func NewMetricStore(numSamples int) *MetricStore {
-	return &MetricStore{
-		numSamples: int(numSamples),
-		histograms: make(map[string]metric.Histogram),
-		index:      make(map[string]MetricID),
-		metrics:    make(map[MetricID]*RingBuffer),
-	}
+	st := &MetricStore{numSamples: int(numSamples)}
+	st.Reset()
+	return st
+}
+
+// Reset removes all the stored series. Streams bound to them are not closed, and must
+// not be used anymore.
+func (st *MetricStore) Reset() {
+	st.nextMetricID = 0
+	st.histograms = make(map[string]metric.Histogram)
+	st.history = make(map[string]*histogramHistory)
+	st.natives = make(map[string]metric.NativeHistogram)
+	st.summaries = make(map[string]metric.Summary)
+	st.metadata = make(map[string]metric.Metadata)
+	st.index = make(map[string]MetricID)
+	st.metrics = make(map[MetricID]*RingBuffer)
 }
 
+// UpdateHistograms records the latest snapshot of each histogram, and keeps as many past snapshots
+// as samples of a series. As for summaries, buckets, sum and count are also kept as regular series,
+// so that they can be queried.
 func (st *MetricStore) UpdateHistograms(hs map[string]metric.Histogram) {
 	maps.Copy(st.histograms, hs)
+
+	for k, h := range hs {
+		ts := sampleTimestamp(h.Timestamp)
+
+		hh, ok := st.history[k]
+		if !ok {
+			hh = &histogramHistory{snapshots: make([]metric.Histogram, st.numSamples)}
+			st.history[k] = hh
+		}
+
+		h.Timestamp = ts
+		hh.add(h)
+
+		for _, m := range h.Series() {
+			st.Update(&m)
+		}
+	}
+}
+
+func (st *MetricStore) UpdateNativeHistograms(hs map[string]metric.NativeHistogram) {
+	maps.Copy(st.natives, hs)
+}
+
+// UpdateSummaries records the latest snapshot of each summary. The history of quantiles,
+// sum and count is kept as regular series, so that quantiles can be plotted over time.
+func (st *MetricStore) UpdateSummaries(ss map[string]metric.Summary) {
+	maps.Copy(st.summaries, ss)
+
+	for _, s := range ss {
+		ts := sampleTimestamp(s.Timestamp)
+		for i, q := range s.Quantiles {
+			st.getMetric(s.QuantileKey(i)).Add(metric.Sample{Timestamp: ts, Value: q.Value})
+		}
+
+		st.Update(&metric.RawMetric{Name: s.Name + "_sum", Labels: s.Labels, Value: s.Sum, Timestamp: ts})
+		st.Update(&metric.RawMetric{Name: s.Name + "_count", Labels: s.Labels, Value: s.Count, Timestamp: ts})
+	}
+}
+
+func (st *MetricStore) UpdateMetadata(md map[string]metric.Metadata) {
+	maps.Copy(st.metadata, md)
+}
+
+// Metadata returns the declared metadata of the family the named series belongs to.
+func (st *MetricStore) Metadata(name string) (metric.Metadata, bool) {
+	return metric.LookupMetadata(st.metadata, name)
 }
 
 func (st *MetricStore) Update(m *metric.RawMetric) {
 	sort.Slice(m.Labels, func(i, j int) bool {
-		return m.Labels[i].Name < m.Labels[j].Value
+		return m.Labels[i].Name < m.Labels[j].Name
 	})
 
 	key := metric.MetricKey{
@@ -63,7 +171,18 @@ func (st *MetricStore) Update(m *metric.RawMetric) {
 	}
 
 	buf := st.getMetric(key)
-	buf.Add(m.Value)
+	buf.Add(metric.Sample{
+		Timestamp: sampleTimestamp(m.Timestamp),
+		Value:     m.Value,
+	})
+}
+
+// sampleTimestamp defaults to the current time for samples without a timestamp.
+func sampleTimestamp(ts int64) int64 {
+	if ts == 0 {
+		return time.Now().UnixMilli()
+	}
+	return ts
 }
 
 func (st *MetricStore) getMetric(key metric.MetricKey) *RingBuffer {
@@ -79,7 +198,11 @@ func (st *MetricStore) getMetric(key metric.MetricKey) *RingBuffer {
 	st.nextMetricID++
 
 	buf := &RingBuffer{
-		values: make([]float64, st.numSamples),
+		key: metric.MetricKey{
+			Name:   key.Name,
+			Labels: slices.Clone(key.Labels),
+		},
+		samples: make([]metric.Sample, st.numSamples),
 	}
 
 	st.metrics[id] = buf
@@ -90,14 +213,33 @@ type Stream struct {
 	st *MetricStore
 
 	key string
-	ch  chan float64
+	ch  chan StreamSample
 }
 
 func (s *Stream) Close() {
 	s.st.close(s.key)
 }
 
-func (st *MetricStore) Samples(key metric.MetricKey, onSample func(float64)) int {
+// Series calls onSeries for the key of each stored series.
+func (st *MetricStore) Series(onSeries func(key metric.MetricKey)) {
+	for _, buf := range st.metrics {
+		onSeries(buf.key)
+	}
+}
+
+// Histograms calls onHistogram for the key of each classic and native histogram.
+func (st *MetricStore) Histograms(onHistogram func(key metric.MetricKey)) {
+	for _, h := range st.histograms {
+		onHistogram(metric.MetricKey{Name: h.Name, Labels: h.Labels})
+	}
+
+	for _, h := range st.natives {
+		onHistogram(metric.MetricKey{Name: h.Name, Labels: h.Labels})
+	}
+}
+
+// Samples calls onSample for the samples of the series, from the oldest to the newest.
+func (st *MetricStore) Samples(key metric.MetricKey, onSample func(metric.Sample)) int {
 	id, has := st.index[key.String()]
 	if !has {
 		return -1
@@ -105,27 +247,54 @@ func (st *MetricStore) Samples(key metric.MetricKey, onSample func(float64)) int
 
 	buf := st.metrics[id]
 
-	end := min(int(buf.n), len(buf.values))
+	count := min(int(buf.n), len(buf.samples))
 
-	start := (buf.next - int(buf.n) + len(buf.values)) % len(buf.values)
-	for i := start; i < end; i++ {
-		onSample(buf.values[i%len(buf.values)])
+	start := (buf.next - count + len(buf.samples)) % len(buf.samples)
+	for i := 0; i < count; i++ {
+		onSample(buf.samples[(start+i)%len(buf.samples)])
 	}
 	return int(buf.n)
 }
 
-func (st *MetricStore) GetHist(mk metric.MetricKey) *metric.Histogram {
-	h, ok := st.histograms[mk.String()]
+// HistogramSnapshots calls onSnapshot for the snapshots of the histogram kept
+// within the store, from the oldest to the newest.
+func (st *MetricStore) HistogramSnapshots(mk metric.MetricKey, onSnapshot func(metric.Histogram)) int {
+	hh, ok := st.history[mk.String()]
 	if !ok {
-		panic(mk.String())
+		return -1
+	}
+
+	start := (hh.next - hh.n + len(hh.snapshots)) % len(hh.snapshots)
+	for i := 0; i < hh.n; i++ {
+		onSnapshot(hh.snapshots[(start+i)%len(hh.snapshots)])
 	}
-	return &h
+	return hh.n
+}
+
+// LookupHist returns the histogram with the given key, if it has been scraped.
+func (st *MetricStore) LookupHist(mk metric.MetricKey) (*metric.Histogram, bool) {
+	h, ok := st.histograms[mk.String()]
+	return &h, ok
+}
+
+// LookupNativeHist returns the native histogram with the given key, if it has been scraped.
+func (st *MetricStore) LookupNativeHist(mk metric.MetricKey) (*metric.NativeHistogram, bool) {
+	h, ok := st.natives[mk.String()]
+	return &h, ok
+}
+
+// LookupSummary returns the summary with the given key, if it has been scraped.
+func (st *MetricStore) LookupSummary(mk metric.MetricKey) (*metric.Summary, bool) {
+	s, ok := st.summaries[mk.String()]
+	return &s, ok
 }
 
-func (st *MetricStore) Bind(key metric.MetricKey, outChan chan float64, n int) *Stream {
+// Bind forwards the samples subsequently added to the series to outChan,
+// tagging them with the given plot line.
+func (st *MetricStore) Bind(key metric.MetricKey, outChan chan StreamSample, line int, n int) *Stream {
 	labels := key.Labels
 	sort.Slice(labels, func(i, j int) bool {
-		return labels[i].Name < labels[j].Value
+		return labels[i].Name < labels[j].Name
 	})
 
 	id, has := st.index[key.String()]
@@ -135,6 +304,7 @@ func (st *MetricStore) Bind(key metric.MetricKey, outChan chan float64, n int) *
 
 	buf := st.metrics[id]
 	buf.ch = outChan
+	buf.line = line
 	buf.bindN = uint64(n)
 
 	return &Stream{
@@ -0,0 +1,81 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ostafen/proq/pkg/metric"
+)
+
+func TestSamples(t *testing.T) {
+	st := NewMetricStore(3)
+
+	key := metric.MetricKey{Name: "requests_total"}
+	for i, ts := range []int64{1000, 2000, 2000, 1500, 3000, 4000} {
+		st.Update(&metric.RawMetric{Name: key.Name, Value: float64(i), Timestamp: ts})
+	}
+
+	var samples []metric.Sample
+	n := st.Samples(key, func(s metric.Sample) {
+		samples = append(samples, s)
+	})
+
+	require.Equal(t, 4, n)
+	require.Equal(t, []metric.Sample{
+		{Timestamp: 2000, Value: 1},
+		{Timestamp: 3000, Value: 4},
+		{Timestamp: 4000, Value: 5},
+	}, samples)
+
+	require.Equal(t, -1, st.Samples(metric.MetricKey{Name: "missing"}, func(metric.Sample) {}))
+}
+
+func TestHistogramSnapshots(t *testing.T) {
+	st := NewMetricStore(2)
+
+	key := metric.MetricKey{Name: "latency_seconds"}
+	for i, ts := range []int64{1000, 2000, 2000, 3000} {
+		st.UpdateHistograms(map[string]metric.Histogram{
+			key.String(): {
+				Name:      key.Name,
+				Bins:      []metric.Bin{{Value: 1, Count: uint64(i)}},
+				Timestamp: ts,
+			},
+		})
+	}
+
+	var timestamps []int64
+	n := st.HistogramSnapshots(key, func(h metric.Histogram) {
+		timestamps = append(timestamps, h.Timestamp)
+	})
+
+	require.Equal(t, 2, n)
+	require.Equal(t, []int64{2000, 3000}, timestamps)
+
+	var buckets []metric.Sample
+	st.Samples(metric.MetricKey{Name: "latency_seconds_bucket", Labels: []metric.Label{{Name: "le", Value: "1"}}}, func(s metric.Sample) {
+		buckets = append(buckets, s)
+	})
+	require.Equal(t, []metric.Sample{{Timestamp: 2000, Value: 1}, {Timestamp: 3000, Value: 3}}, buckets)
+}
+
+func TestHistograms(t *testing.T) {
+	st := NewMetricStore(2)
+
+	classic := metric.MetricKey{Name: "latency_seconds", Labels: []metric.Label{{Name: "instance", Value: "a"}}}
+	native := metric.MetricKey{Name: "latency_seconds", Labels: []metric.Label{{Name: "instance", Value: "b"}}}
+
+	st.UpdateHistograms(map[string]metric.Histogram{
+		classic.String(): {Name: classic.Name, Labels: classic.Labels, Timestamp: 1000},
+	})
+	st.UpdateNativeHistograms(map[string]metric.NativeHistogram{
+		native.String(): {Name: native.Name, Labels: native.Labels},
+	})
+
+	var keys []metric.MetricKey
+	st.Histograms(func(key metric.MetricKey) {
+		keys = append(keys, key)
+	})
+	require.ElementsMatch(t, []metric.MetricKey{classic, native}, keys)
+}
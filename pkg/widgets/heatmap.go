@@ -0,0 +1,157 @@
+package widgets
+
+import (
+	"image"
+	"math"
+	"time"
+
+	ui "github.com/ostafen/termui/v3"
+
+	"github.com/ostafen/proq/pkg/metric"
+)
+
+// HeatmapColumn holds the number of observations which fell in each bucket
+// of a histogram between two of its snapshots.
+type HeatmapColumn struct {
+	Start  int64
+	End    int64
+	Counts []float64
+}
+
+// heatmapPalette goes from dark blue to red, through the 256 colors palette.
+var heatmapPalette = []ui.Color{17, 19, 21, 27, 33, 39, 45, 49, 46, 118, 190, 226, 214, 208, 202, 196}
+
+// Heatmap draws the distribution of a histogram over time, with a column for each
+// interval between snapshots and a row for each bucket. When there are more buckets
+// than rows, adjacent buckets are merged.
+type Heatmap struct {
+	*ui.Block
+
+	// Bounds holds the upper bound of each bucket.
+	Bounds []float64
+
+	columns []HeatmapColumn
+
+	window time.Duration
+	end    int64
+}
+
+func NewHeatmap(window time.Duration) *Heatmap {
+	return &Heatmap{
+		Block:  ui.NewBlock(),
+		window: window,
+	}
+}
+
+func (h *Heatmap) SetData(bounds []float64, columns []HeatmapColumn, now time.Time) {
+	h.Bounds = bounds
+	h.columns = columns
+	h.end = now.UnixMilli()
+}
+
+func (h *Heatmap) Draw(buf *ui.Buffer) {
+	h.Block.Draw(buf)
+
+	if len(h.Bounds) == 0 {
+		return
+	}
+
+	rows := min(len(h.Bounds), h.Inner.Dy()-xAxisLabelsHeight)
+	if rows <= 0 {
+		return
+	}
+	groupSize := (len(h.Bounds) + rows - 1) / rows
+	rows = (len(h.Bounds) + groupSize - 1) / groupSize
+
+	labels := make([]string, rows)
+	labelsWidth := 0
+	for r := range labels {
+		upper := h.Bounds[min((r+1)*groupSize, len(h.Bounds))-1]
+		labels[r] = formatBound(upper)
+		labelsWidth = max(labelsWidth, len(labels[r]))
+	}
+
+	drawArea := image.Rect(
+		h.Inner.Min.X+labelsWidth+1, h.Inner.Min.Y,
+		h.Inner.Max.X, h.Inner.Max.Y-xAxisLabelsHeight,
+	)
+	if drawArea.Dx() < 1 {
+		return
+	}
+
+	rowRange := func(r int) (int, int) {
+		return drawArea.Max.Y - (r+1)*drawArea.Dy()/rows, drawArea.Max.Y - r*drawArea.Dy()/rows
+	}
+
+	labelStyle := ui.NewStyle(ui.ColorWhite)
+	for r, label := range labels {
+		y0, y1 := rowRange(r)
+		buf.SetString(label, labelStyle, image.Pt(h.Inner.Min.X+labelsWidth-len(label), (y0+y1-1)/2))
+	}
+
+	grouped := make([][]float64, len(h.columns))
+	maxCount := 0.0
+	for i, c := range h.columns {
+		grouped[i] = make([]float64, rows)
+		for b, count := range c.Counts {
+			grouped[i][min(b/groupSize, rows-1)] += count
+		}
+
+		for _, count := range grouped[i] {
+			maxCount = math.Max(maxCount, count)
+		}
+	}
+
+	start := h.end - h.window.Milliseconds()
+	xPos := func(ts int64) int {
+		return drawArea.Min.X + int(float64(ts-start)/float64(h.window.Milliseconds())*float64(drawArea.Dx()))
+	}
+
+	for i, c := range h.columns {
+		x0, x1 := max(xPos(c.Start), drawArea.Min.X), min(xPos(c.End), drawArea.Max.X)
+		if x1 <= x0 {
+			x1 = x0 + 1
+		}
+
+		for r, count := range grouped[i] {
+			if count <= 0 {
+				continue
+			}
+
+			color := heatmapPalette[int(count/maxCount*float64(len(heatmapPalette)-1))]
+			cell := ui.NewCell(' ', ui.NewStyle(ui.ColorClear, color))
+
+			y0, y1 := rowRange(r)
+			buf.Fill(cell, image.Rect(x0, y0, min(x1, drawArea.Max.X), y1))
+		}
+	}
+
+	for i := 0; i <= DefaultXTicks; i++ {
+		ts := start + h.window.Milliseconds()*int64(i)/DefaultXTicks
+		label := time.UnixMilli(ts).Format(xAxisTimeFormat)
+
+		x := drawArea.Min.X + (drawArea.Dx()-1)*i/DefaultXTicks - len(label)/2
+		x = max(h.Inner.Min.X, min(x, h.Inner.Max.X-len(label)))
+		buf.SetString(label, labelStyle, image.Pt(x, h.Inner.Max.Y-1))
+	}
+}
+
+// HeatmapColumns computes the observations made between consecutive snapshots of a histogram,
+// which must be sorted by timestamp.
+func HeatmapColumns(snapshots []metric.Histogram) []HeatmapColumn {
+	if len(snapshots) < 2 {
+		return nil
+	}
+
+	columns := make([]HeatmapColumn, 0, len(snapshots)-1)
+	for i := 1; i < len(snapshots); i++ {
+		prev, curr := snapshots[i-1], snapshots[i]
+
+		columns = append(columns, HeatmapColumn{
+			Start:  prev.Timestamp,
+			End:    curr.Timestamp,
+			Counts: metric.BucketCounts(metric.DeltaBins(prev.Bins, curr.Bins)),
+		})
+	}
+	return columns
+}
@@ -2,6 +2,7 @@ package widgets
 
 import (
 	"math"
+	"slices"
 	"strconv"
 
 	ui "github.com/ostafen/termui/v3"
@@ -14,39 +15,121 @@ type Histogram struct {
 	*widgets.BarChart
 }
 
+const (
+	barWidth  = 7
+	minBarGap = 2
+)
+
+// NewHistogram renders the cumulative counts of the bins of a classic histogram.
 func NewHistogram(hist *metric.Histogram, width int) *Histogram {
-	const barWidth = 7
+	counts := make([]float64, len(hist.Bins))
+	for i, b := range hist.Bins {
+		counts[i] = float64(b.Count)
+	}
+	return newClassicHistogram(hist.Name, hist.Bins, counts, true, width)
+}
+
+// NewBucketHistogram renders the number of observations which fall in each bucket
+// of a classic histogram, given its cumulative bins.
+func NewBucketHistogram(name string, bins []metric.Bin, width int) *Histogram {
+	return newClassicHistogram(name, bins, metric.BucketCounts(bins), false, width)
+}
+
+func newClassicHistogram(name string, bins []metric.Bin, counts []float64, cumulative bool, width int) *Histogram {
+	if len(bins) == 0 {
+		return newHistogram(name, []string{""}, []float64{0}, 0)
+	}
+
+	bounds := make([]float64, len(bins))
+	for i, b := range bins {
+		bounds[i] = b.Value
+	}
+	counts = slices.Clone(counts)
 
 	// if there is not enough space to render all the bins,
 	// merge the last bins to the +Inf bin.
-	barGap := (width - barWidth*(len(hist.Bins))) / len(hist.Bins)
-	for i := len(hist.Bins) - 1; i > 0; i-- {
-		if barGap >= 2 {
+	barGap := (width - barWidth*(len(bins))) / len(bins)
+	for i := len(bounds) - 1; i > 0; i-- {
+		if barGap >= minBarGap {
 			break
 		}
 
-		hist.Bins[i-1].Count += hist.Bins[i].Count
-		hist.Bins[i-1].Value = math.Inf(1)
-		hist.Bins = hist.Bins[:len(hist.Bins)-1]
+		if cumulative {
+			counts[i-1] = counts[i]
+		} else {
+			counts[i-1] += counts[i]
+		}
+		bounds[i-1] = math.Inf(1)
+
+		bounds = bounds[:i]
+		counts = counts[:i]
 
-		barGap = (width - barWidth*(len(hist.Bins))) / len(hist.Bins)
+		barGap = (width - barWidth*(len(bounds))) / len(bounds)
 	}
 
-	bucketValues := make([]float64, len(hist.Bins))
-	bucketLabels := make([]string, len(hist.Bins))
+	bucketLabels := make([]string, len(bounds))
+	for i, b := range bounds {
+		bucketLabels[i] = formatBound(b)
+	}
+	return newHistogram(name, bucketLabels, counts, barGap)
+}
 
-	var maxVal float64 = hist.Bins[0].Value
-	for i, b := range hist.Bins {
-		bucketLabels[i] = strconv.FormatFloat(b.Value, 'f', 0, 64)
-		bucketValues[i] = float64(b.Count)
+// NewNativeHistogram renders the buckets of a native histogram. When they don't fit
+// the available width, the resolution of the histogram is lowered by merging adjacent
+// buckets, so that bucket boundaries remain powers of two.
+func NewNativeHistogram(hist *metric.NativeHistogram, width int) *Histogram {
+	maxBuckets := width / (barWidth + minBarGap)
+	if maxBuckets < 1 {
+		maxBuckets = 1
+	}
+
+	buckets := hist.Buckets()
+	for len(buckets) > maxBuckets && hist.Schema > metric.MinNativeSchema {
+		hist = hist.Reduce(hist.Schema - 1)
+		buckets = hist.Buckets()
+	}
+
+	// the lowest resolution could still be too high for very narrow terminals.
+	for len(buckets) > maxBuckets {
+		n := len(buckets)
+		buckets[n-2].Count += buckets[n-1].Count
+		buckets[n-2].Upper = buckets[n-1].Upper
+		buckets = buckets[:n-1]
+	}
+
+	if len(buckets) == 0 {
+		buckets = []metric.NativeBucket{{}}
+	}
+
+	bucketValues := make([]float64, len(buckets))
+	bucketLabels := make([]string, len(buckets))
+
+	for i, b := range buckets {
+		bucketLabels[i] = formatBound(b.Upper)
+		bucketValues[i] = b.Count
+	}
+
+	barGap := (width - barWidth*len(buckets)) / len(buckets)
+	return newHistogram(hist.Name, bucketLabels, bucketValues, barGap)
+}
+
+func formatBound(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', 3, 64)
+}
 
-		if float64(b.Count) > maxVal {
-			maxVal = float64(b.Count)
+func newHistogram(title string, bucketLabels []string, bucketValues []float64, barGap int) *Histogram {
+	var maxVal float64
+	for _, v := range bucketValues {
+		if v > maxVal {
+			maxVal = v
 		}
 	}
 
 	barChart := widgets.NewBarChart()
-	barChart.Title = hist.Name
+	barChart.Title = title
 	barChart.Data = bucketValues
 	barChart.Labels = bucketLabels
 	barChart.BarWidth = barWidth
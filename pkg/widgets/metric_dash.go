@@ -4,6 +4,8 @@ import (
 	"time"
 
 	ui "github.com/ostafen/termui/v3"
+
+	"github.com/ostafen/proq/pkg/metric"
 )
 
 const (
@@ -12,10 +14,16 @@ const (
 )
 
 type MetricsDash struct {
-	Plot   *MetricPlot
-	List   *MetricList
-	Hist   *Histogram
-	Prompt *Prompt
+	Plot    *MetricPlot
+	List    *MetricList
+	Hist    *Histogram
+	Heatmap *Heatmap
+	Prompt  *Prompt
+	Status  *StatusBar
+
+	// Grid replaces the explorer view, made of the plot and the list, when ShowGrid is set.
+	Grid     *PanelGrid
+	ShowGrid bool
 }
 
 func NewMetricDash(
@@ -23,7 +31,10 @@ func NewMetricDash(
 	displayInterval time.Duration,
 ) *MetricsDash {
 	return &MetricsDash{
-		Prompt: NewPrompt(),
+		Prompt:  NewPrompt(),
+		Status:  NewStatusBar(),
+		Grid:    NewPanelGrid(),
+		Heatmap: NewHeatmap(displayInterval),
 		Plot: NewMetricPlot(
 			pollInterval,
 			displayInterval,
@@ -31,26 +42,53 @@ func NewMetricDash(
 	}
 }
 
-const yAxisLabelsWidth = 4 // from termui
-
 func (dash *MetricsDash) Resize() {
 	width, height := ui.TerminalDimensions()
 
-	dash.Plot.HorizontalScale = float64(width-yAxisLabelsWidth-1) / float64(dash.Plot.NumSamples-1)
-	dash.Plot.CurrWidth = width
+	const (
+		promptHeight = 3
+		statusHeight = 1
+		barHeight    = promptHeight + statusHeight
+	)
 
-	const barHeight = 3
+	dash.Grid.SetRect(0, 0, width, height-barHeight)
 
 	dash.Plot.SetRect(0, 0, int(float64(width)*WidthRatio), int(float64(height)*HeightRatio))
+	dash.Heatmap.SetRect(0, 0, int(float64(width)*WidthRatio), int(float64(height)*HeightRatio))
 	dash.List.SetRect(0, int(float64(height)*HeightRatio), width, height-barHeight)
 
-	dash.Prompt.SetRect(0, height-barHeight, width, height)
+	dash.Prompt.SetRect(0, height-barHeight, width, height-statusHeight)
+	dash.Status.SetRect(0, height-statusHeight, width, height)
 
 	dash.Render()
 }
 
 func (dash *MetricsDash) Render() {
-	ui.Render(dash.List, dash.Plot, dash.Prompt)
+	ui.Clear()
+
+	if dash.ShowGrid {
+		ui.Render(dash.Grid, dash.Prompt, dash.Status)
+		return
+	}
+	ui.Render(dash.List, dash.Plot, dash.Prompt, dash.Status)
+}
+
+// RenderExplorer renders widgets of the explorer view, unless the grid is shown in its place.
+func (dash *MetricsDash) RenderExplorer(items ...ui.Drawable) {
+	if !dash.ShowGrid {
+		ui.Render(items...)
+	}
+}
+
+// ToggleGrid switches between the explorer view and the grid of panels.
+func (dash *MetricsDash) ToggleGrid() {
+	dash.SetShowGrid(!dash.ShowGrid)
+	dash.Render()
+}
+
+func (dash *MetricsDash) SetShowGrid(show bool) {
+	dash.ShowGrid = show
+	dash.List.Hidden = show
 }
 
 func (dash *MetricsDash) OnKeyPressed(key string) bool {
@@ -60,7 +98,7 @@ func (dash *MetricsDash) OnKeyPressed(key string) bool {
 		drawables = append(drawables, dash.Prompt)
 	}
 
-	if dash.List.OnKeyPressed(key) {
+	if !dash.ShowGrid && dash.List.OnKeyPressed(key) {
 		drawables = append(drawables, dash.List)
 	}
 
@@ -72,14 +110,18 @@ func (dash *MetricsDash) SetMetricList(metrics []MetricInfo) {
 	dash.List.AddMetrics(metrics)
 }
 
-func (dash *MetricsDash) ShowHistograms() {
-	dash.List.ShowHistograms()
+func (dash *MetricsDash) ShowType(t metric.MetricType) {
+	dash.List.ShowType(t)
 }
 
 func (dash *MetricsDash) FilterMetrics(filter string) error {
 	return dash.List.Filter(filter)
 }
 
+func (dash *MetricsDash) FilterByLabels(pattern string, labels ...string) error {
+	return dash.List.FilterByLabels(pattern, labels...)
+}
+
 func (dash *MetricsDash) ResetMetrics() {
 	dash.List.Reset()
 }
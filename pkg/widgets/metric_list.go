@@ -3,6 +3,7 @@ package widgets
 import (
 	"fmt"
 	"regexp"
+	"slices"
 	"strings"
 
 	ui "github.com/ostafen/termui/v3"
@@ -11,10 +12,24 @@ import (
 	"github.com/ostafen/termui/v3/widgets"
 )
 
+// MetricKind tells how a metric is stored and rendered.
+type MetricKind int
+
+const (
+	KindSeries MetricKind = iota
+	KindHistogram
+	KindNativeHistogram
+	KindSummary
+)
+
 type MetricInfo struct {
 	Name   string
 	Labels []metric.Label
-	IsHist bool
+	Kind   MetricKind
+
+	Type metric.MetricType
+	Help string
+	Unit string
 }
 
 func (mi *MetricInfo) Key() metric.MetricKey {
@@ -24,6 +39,33 @@ func (mi *MetricInfo) Key() metric.MetricKey {
 	}
 }
 
+// IsCounter tells whether the metric is a counter, either by declaration or by naming convention.
+func (mi *MetricInfo) IsCounter() bool {
+	return mi.Kind == KindSeries && (mi.Type == metric.TypeCounter || strings.HasSuffix(mi.Name, "_total"))
+}
+
+// Title describes the metric along with its declared type and help text.
+func (mi *MetricInfo) Title() string {
+	var details []string
+	if mi.Type != "" && mi.Type != metric.TypeUnknown {
+		details = append(details, string(mi.Type))
+	}
+
+	if mi.Unit != "" {
+		details = append(details, mi.Unit)
+	}
+
+	title := mi.Name
+	if len(details) > 0 {
+		title += " (" + strings.Join(details, ", ") + ")"
+	}
+
+	if mi.Help != "" {
+		title += ": " + strings.ReplaceAll(mi.Help, "\n", " ")
+	}
+	return title
+}
+
 type MetricList struct {
 	selectedRow int
 
@@ -32,6 +74,12 @@ type MetricList struct {
 	metrics          []MetricInfo
 	displayedMetrics []MetricInfo
 
+	// pinned holds the series overlaid on the plot along with the selected one.
+	pinned []MetricInfo
+
+	// Hidden prevents the list from being rendered when updated.
+	Hidden bool
+
 	onMetricSelected func(m MetricInfo)
 
 	*widgets.List
@@ -58,10 +106,58 @@ func (l *MetricList) OnKeyPressed(key string) bool {
 		return l.scroll(-1)
 	case "<Down>":
 		return l.scroll(1)
+	case "<Right>":
+		return l.setPinned(true)
+	case "<Left>":
+		return l.setPinned(false)
 	}
 	return false
 }
 
+// setPinned pins or unpins the selected series, and selects it again
+// so that the plot reflects the change.
+func (l *MetricList) setPinned(pin bool) bool {
+	if l.selectedRow < 0 || l.selectedRow >= len(l.displayedMetrics) {
+		return false
+	}
+
+	m := l.displayedMetrics[l.selectedRow]
+	if m.Kind != KindSeries {
+		return false
+	}
+
+	idx := l.pinnedIndex(m)
+	switch {
+	case pin && idx < 0:
+		l.pinned = append(l.pinned, m)
+	case !pin && idx >= 0:
+		l.pinned = slices.Delete(l.pinned, idx, idx+1)
+	default:
+		return false
+	}
+
+	l.RenderList()
+	return l.selectMetric()
+}
+
+func (l *MetricList) pinnedIndex(m MetricInfo) int {
+	key := m.Key()
+	return slices.IndexFunc(l.pinned, func(p MetricInfo) bool {
+		pk := p.Key()
+		return pk.String() == key.String()
+	})
+}
+
+// Pinned returns the pinned series, in the order they were pinned.
+func (l *MetricList) Pinned() []MetricInfo {
+	return l.pinned
+}
+
+func (l *MetricList) ClearPinned() {
+	l.pinned = nil
+	l.RenderList()
+}
+
 func (l *MetricList) scroll(direction int) bool {
 	if l.selectedRow+direction < 0 || l.selectedRow+direction >= len(l.Rows) {
 		return false
@@ -88,10 +184,10 @@ func (l *MetricList) selectMetric() bool {
 	return true
 }
 
-func (l *MetricList) ShowHistograms() {
+func (l *MetricList) ShowType(t metric.MetricType) {
 	metrics := make([]MetricInfo, 0, len(l.allMetrics))
 	for _, m := range l.metrics {
-		if m.IsHist {
+		if m.Type == t {
 			metrics = append(metrics, m)
 		}
 	}
@@ -147,6 +243,32 @@ func (l *MetricList) Filter(pattern string) error {
 	return nil
 }
 
+// FilterByLabels shows the metrics having any of the given labels matching the pattern.
+func (l *MetricList) FilterByLabels(pattern string, labels ...string) error {
+	exp, err := regexp.Compile(wildcardToRegex(pattern))
+	if err != nil {
+		return err
+	}
+
+	metrics := make([]MetricInfo, 0, len(l.metrics))
+	for _, m := range l.metrics {
+		for _, lbl := range m.Labels {
+			if slices.Contains(labels, lbl.Name) && exp.MatchString(lbl.Value) {
+				metrics = append(metrics, m)
+				break
+			}
+		}
+	}
+
+	if len(metrics) == 0 {
+		return fmt.Errorf("\"%s\": no metric matches the specified filter", pattern)
+	}
+	l.displayedMetrics = metrics
+
+	l.RenderList()
+	return nil
+}
+
 func (l *MetricList) Reset() {
 	l.displayedMetrics = l.metrics
 	l.RenderList()
@@ -178,7 +300,11 @@ func (l *MetricList) RenderList() {
 			Labels: m.Labels,
 		}
 
-		rows[i] = "- " + mk.String()
+		if l.pinnedIndex(m) >= 0 {
+			rows[i] = "+ " + mk.String()
+		} else {
+			rows[i] = "- " + mk.String()
+		}
 	}
 
 	if len(rows) == 0 {
@@ -189,5 +315,7 @@ func (l *MetricList) RenderList() {
 
 	l.Title = fmt.Sprintf("Metrics (%d/%d)", len(rows), len(l.allMetrics))
 
-	ui.Render(l.List)
+	if !l.Hidden {
+		ui.Render(l.List)
+	}
 }
@@ -1,97 +1,276 @@
 package widgets
 
 import (
+	"fmt"
+	"image"
+	"math"
 	"time"
 
 	ui "github.com/ostafen/termui/v3"
-	"github.com/ostafen/termui/v3/widgets"
+
+	"github.com/ostafen/proq/pkg/metric"
 )
 
+// MetricPlot draws series on a time axis covering the display window.
+// Points are placed according to their timestamp, and lines are broken
+// where consecutive samples are too far apart, e.g. because of a failed scrape.
 type MetricPlot struct {
-	*widgets.Plot
+	*ui.Block
+
+	LineColors []ui.Color
+
+	// Legend holds the name of each line, drawn in the top right corner.
+	Legend []string
+
+	// Rate plots the per-second rate of the lines, which must be counters,
+	// rather than their raw values.
+	Rate bool
+
+	// Thresholds are drawn as horizontal lines, when in the range of the plotted values.
+	Thresholds []Threshold
 
-	NumSamples   int
-	numTicks     int
-	tickInterval time.Duration
-	currMaxTick  time.Duration
+	lines [][]metric.Sample
 
-	CurrWidth int
-	start     time.Time
+	pollInterval time.Duration
+	window       time.Duration
+	end          int64
 }
 
-const DefaultXTicks = 5
+const (
+	DefaultXTicks = 5
+
+	// samples farther apart than maxGapIntervals poll intervals are not joined.
+	maxGapIntervals = 2
+
+	xAxisLabelsHeight = 1
+	xAxisTimeFormat   = "15:04:05"
+)
 
 func NewMetricPlot(
-	sampleRate time.Duration,
+	pollInterval time.Duration,
 	windowInterval time.Duration,
 ) *MetricPlot {
-	plot := widgets.NewPlot()
-	plot.Title = "Metric Data"
-	plot.Data = [][]float64{}
-	plot.AxesColor = ui.ColorBlack
-	plot.LineColors[0] = ui.ColorGreen
-	plot.Marker = widgets.MarkerBraille
+	block := ui.NewBlock()
+	block.Title = "Metric Data"
 
-	maxWindowSamples := int(windowInterval/sampleRate) + 1
+	return &MetricPlot{
+		Block: block,
+		LineColors: []ui.Color{
+			ui.ColorGreen,
+			ui.ColorYellow,
+			ui.ColorCyan,
+			ui.ColorMagenta,
+			ui.ColorRed,
+			ui.ColorBlue,
+			ui.ColorWhite,
+		},
+		pollInterval: pollInterval,
+		window:       windowInterval,
+	}
+}
 
-	xTicks := min(DefaultXTicks, maxWindowSamples)
-	tickInterval := windowInterval / time.Duration(xTicks)
+// SetLines replaces the plotted lines, along with their legend.
+func (p *MetricPlot) SetLines(lines [][]metric.Sample, legend []string) {
+	p.lines = lines
+	p.Legend = legend
 
-	labels := make([]string, xTicks+1)
-	for i := range labels {
-		axisLabel := tickInterval * time.Duration(i)
-		labels[i] = axisLabel.String()
+	for _, line := range lines {
+		if n := len(line); n > 0 {
+			p.end = max(p.end, line[n-1].Timestamp)
+		}
 	}
-	plot.DataLabels = labels
+}
 
-	return &MetricPlot{
-		Plot:         plot,
-		NumSamples:   maxWindowSamples,
-		numTicks:     xTicks,
-		tickInterval: tickInterval,
-		currMaxTick:  windowInterval,
-		start:        time.Now(),
-		CurrWidth:    -1,
+// Reset removes the plotted lines, and moves the time axis back to the
+// time of the next samples, which can be older than the removed ones.
+func (p *MetricPlot) Reset() {
+	p.lines = nil
+	p.Legend = nil
+	p.end = 0
+}
+
+func (p *MetricPlot) Update(line int, s metric.Sample) {
+	if line >= len(p.lines) {
+		return
 	}
+
+	p.lines[line] = append(p.lines[line], s)
+	p.Advance(time.UnixMilli(s.Timestamp))
 }
 
-func (p *MetricPlot) Update(sample float64) {
-	n := p.NumSamples / 2
+// Advance moves the right end of the time axis, so that missed
+// samples show up as a gap even before the next sample arrives.
+func (p *MetricPlot) Advance(now time.Time) {
+	p.end = max(p.end, now.UnixMilli())
 
-	if len(p.Data[0])+1 > p.NumSamples {
-		p.Data[0] = append(p.Data[0][n+1:], sample)
-		p.Refresh(time.Since(p.start))
-	} else {
-		p.Data[0] = append(p.Data[0], sample)
+	start := p.start()
+	for i, line := range p.lines {
+		n := 0
+		for n < len(line) && line[n].Timestamp < start {
+			n++
+		}
+		p.lines[i] = line[n:]
 	}
+}
 
-	p.MaxVal = max(p.Data[0])
-
-	ui.Render(p)
+func (p *MetricPlot) start() int64 {
+	return p.end - p.window.Milliseconds()
 }
 
-func max(values []float64) float64 {
-	if len(values) == 0 {
-		return 0
+func (p *MetricPlot) Draw(buf *ui.Buffer) {
+	p.Block.Draw(buf)
+
+	lines := p.lines
+	if p.Rate {
+		lines = make([][]metric.Sample, len(p.lines))
+		for i, line := range p.lines {
+			lines[i] = metric.Rate(line)
+		}
+	}
+
+	minVal, maxVal, ok := valueRange(lines)
+	if !ok {
+		return
+	}
+
+	yLabels := make([]string, max(2, min(10, p.Inner.Dy()/2)))
+	labelsWidth := 0
+	for i := range yLabels {
+		v := minVal + (maxVal-minVal)*float64(i)/float64(len(yLabels)-1)
+		yLabels[i] = formatValue(v)
+		labelsWidth = max(labelsWidth, len(yLabels[i]))
+	}
+
+	drawArea := image.Rect(
+		p.Inner.Min.X+labelsWidth+1, p.Inner.Min.Y,
+		p.Inner.Max.X, p.Inner.Max.Y-xAxisLabelsHeight-1,
+	)
+	if drawArea.Dx() < 2 || drawArea.Dy() < 2 {
+		return
+	}
+
+	p.drawAxes(buf, drawArea, yLabels)
+
+	canvas := ui.NewCanvas()
+	canvas.Rectangle = drawArea
+
+	start := p.start()
+	maxGap := int64(maxGapIntervals) * p.pollInterval.Milliseconds()
+
+	point := func(s metric.Sample) image.Point {
+		x := float64(s.Timestamp-start) / float64(p.window.Milliseconds())
+		y := (s.Value - minVal) / (maxVal - minVal)
+
+		return image.Pt(
+			drawArea.Min.X*2+int(x*float64(drawArea.Dx()*2-1)),
+			drawArea.Min.Y*4+int((1-y)*float64(drawArea.Dy()*4-1)),
+		)
 	}
 
-	max := values[0]
-	for _, v := range values[1:] {
-		if v > max {
-			max = v
+	for _, t := range p.Thresholds {
+		if t.Value < minVal || t.Value > maxVal {
+			continue
+		}
+
+		from, to := point(metric.Sample{Timestamp: start, Value: t.Value}), point(metric.Sample{Timestamp: p.end, Value: t.Value})
+		for x := from.X; x <= to.X; x += 4 {
+			canvas.SetLine(image.Pt(x, from.Y), image.Pt(min(x+1, to.X), from.Y), t.Color)
 		}
 	}
-	return max
+
+	for i, line := range lines {
+		color := ui.SelectColor(p.LineColors, i)
+
+		var prev *metric.Sample
+		for j := range line {
+			s := &line[j]
+			if math.IsNaN(s.Value) || math.IsInf(s.Value, 0) {
+				prev = nil
+				continue
+			}
+
+			if prev != nil && s.Timestamp-prev.Timestamp <= maxGap {
+				canvas.SetLine(point(*prev), point(*s), color)
+			} else {
+				canvas.SetPoint(point(*s), color)
+			}
+			prev = s
+		}
+	}
+	canvas.Draw(buf)
+
+	p.drawLegend(buf)
+}
+
+// valueRange returns the range of the finite values of the lines.
+func valueRange(lines [][]metric.Sample) (float64, float64, bool) {
+	minVal, maxVal := math.Inf(1), math.Inf(-1)
+	for _, line := range lines {
+		for _, s := range line {
+			if math.IsNaN(s.Value) || math.IsInf(s.Value, 0) {
+				continue
+			}
+			minVal = math.Min(minVal, s.Value)
+			maxVal = math.Max(maxVal, s.Value)
+		}
+	}
+
+	if math.IsInf(minVal, 1) {
+		return 0, 0, false
+	}
+
+	if minVal == maxVal {
+		minVal, maxVal = minVal-1, maxVal+1
+	}
+	return minVal, maxVal, true
 }
 
-func (p *MetricPlot) Refresh(elapsedTime time.Duration) {
-	p.currMaxTick += (p.tickInterval * time.Duration(p.numTicks)) / 2
+func (p *MetricPlot) drawAxes(buf *ui.Buffer, drawArea image.Rectangle, yLabels []string) {
+	axisStyle := ui.NewStyle(ui.ColorWhite)
+
+	originX, originY := drawArea.Min.X-1, drawArea.Max.Y
+
+	buf.SetCell(ui.NewCell(ui.BOTTOM_LEFT, axisStyle), image.Pt(originX, originY))
+	for x := drawArea.Min.X; x < drawArea.Max.X; x++ {
+		buf.SetCell(ui.NewCell(ui.HORIZONTAL_DASH, axisStyle), image.Pt(x, originY))
+	}
+
+	for y := drawArea.Min.Y; y < drawArea.Max.Y; y++ {
+		buf.SetCell(ui.NewCell(ui.VERTICAL_DASH, axisStyle), image.Pt(originX, y))
+	}
+
+	for i, label := range yLabels {
+		y := drawArea.Max.Y - 1 - i*(drawArea.Dy()-1)/(len(yLabels)-1)
+		buf.SetString(label, axisStyle, image.Pt(p.Inner.Min.X, y))
+	}
+
+	start := p.start()
+	for i := 0; i <= DefaultXTicks; i++ {
+		ts := start + p.window.Milliseconds()*int64(i)/DefaultXTicks
+		label := time.UnixMilli(ts).Format(xAxisTimeFormat)
+
+		x := drawArea.Min.X + (drawArea.Dx()-1)*i/DefaultXTicks - len(label)/2
+		x = max(p.Inner.Min.X, min(x, p.Inner.Max.X-len(label)))
+		buf.SetString(label, axisStyle, image.Pt(x, p.Inner.Max.Y-1))
+	}
+}
+
+func (p *MetricPlot) drawLegend(buf *ui.Buffer) {
+	for i, name := range p.Legend {
+		y := p.Inner.Min.Y + i
+		if y >= p.Inner.Max.Y {
+			break
+		}
+
+		s := "━ " + name
+		x := p.Inner.Max.X - len([]rune(s)) - 1
+		buf.SetString(s, ui.NewStyle(ui.SelectColor(p.LineColors, i)), image.Pt(x, y))
+	}
+}
 
-	labels := make([]string, p.numTicks+1)
-	for i := range labels {
-		axisLabel := p.currMaxTick - p.tickInterval*time.Duration(i)
-		labels[len(labels)-i-1] = axisLabel.String()
+func formatValue(v float64) string {
+	if abs := math.Abs(v); abs != 0 && (abs >= 1e5 || abs < 1e-2) {
+		return fmt.Sprintf("%.2e", v)
 	}
-	p.Plot.DataLabels = labels
-	ui.Render(p)
+	return fmt.Sprintf("%.2f", v)
 }
@@ -0,0 +1,286 @@
+package widgets
+
+import (
+	"fmt"
+	"image"
+	"slices"
+	"time"
+
+	ui "github.com/ostafen/termui/v3"
+)
+
+type PanelType string
+
+const (
+	PanelPlot      PanelType = "plot"
+	PanelHistogram PanelType = "histogram"
+	PanelStat      PanelType = "stat"
+)
+
+func ParsePanelType(s string) (PanelType, error) {
+	switch t := PanelType(s); t {
+	case PanelPlot, PanelHistogram, PanelStat:
+		return t, nil
+	case "hist":
+		return PanelHistogram, nil
+	}
+	return "", fmt.Errorf("unknown panel type \"%s\"", s)
+}
+
+// Panel is a cell of the dashboard grid. Plot and stat panels show the result
+// of a query, while histogram panels show the latest snapshot of a histogram.
+type Panel struct {
+	Type  PanelType
+	Title string
+
+	// Query is the expression evaluated by plot and stat panels.
+	Query string
+
+	// Metric is the selector of the histogram shown by histogram panels.
+	Metric string
+
+	Unit       string
+	Thresholds []Threshold
+
+	// Pos optionally places the panel at a given cell of the grid.
+	Pos *GridPos
+
+	Plot *MetricPlot
+	Stat *Stat
+	Hist *Histogram
+
+	// Rect is the area assigned to the panel by the grid.
+	Rect image.Rectangle
+}
+
+// GridPos is a cell of the grid, spanning Width columns and Height rows.
+type GridPos struct {
+	Row, Col      int
+	Width, Height int
+}
+
+func NewQueryPanel(typ PanelType, title, query string, pollInterval, window time.Duration) *Panel {
+	p := &Panel{
+		Type:  typ,
+		Title: title,
+		Query: query,
+	}
+
+	if typ == PanelStat {
+		p.Stat = NewStat()
+		p.Stat.Title = title
+	} else {
+		p.Plot = NewMetricPlot(pollInterval, window)
+		p.Plot.Title = title
+	}
+	return p
+}
+
+// SetDisplay sets the unit and thresholds the values of the panel are displayed with.
+func (p *Panel) SetDisplay(unit string, thresholds []Threshold) {
+	p.Unit = unit
+	p.Thresholds = thresholds
+
+	switch {
+	case p.Stat != nil:
+		p.Stat.Unit = unit
+		p.Stat.Thresholds = thresholds
+	case p.Plot != nil:
+		p.Plot.Thresholds = thresholds
+		if unit != "" {
+			p.Plot.Title = p.Title + " [" + unit + "]"
+		}
+	}
+}
+
+func NewHistogramPanel(title, selector string) *Panel {
+	return &Panel{
+		Type:   PanelHistogram,
+		Title:  title,
+		Metric: selector,
+	}
+}
+
+// widget returns the drawable currently showing the panel, along with its block.
+func (p *Panel) widget() (ui.Drawable, *ui.Block) {
+	switch {
+	case p.Plot != nil:
+		return p.Plot, p.Plot.Block
+	case p.Stat != nil:
+		return p.Stat, p.Stat.Block
+	case p.Hist != nil:
+		p.Hist.Title = p.Title
+		return p.Hist.BarChart, &p.Hist.BarChart.Block
+	}
+
+	// the histogram hasn't been scraped yet.
+	block := ui.NewBlock()
+	block.Title = p.Title
+	return block, block
+}
+
+// PanelGrid lays out panels in rows of Columns cells. Panels of a partially
+// filled row share its whole width.
+type PanelGrid struct {
+	*ui.Block
+
+	Panels  []*Panel
+	Columns int
+
+	focused int
+}
+
+const DefaultGridColumns = 2
+
+func NewPanelGrid() *PanelGrid {
+	block := ui.NewBlock()
+	block.Border = false
+
+	return &PanelGrid{
+		Block:   block,
+		Columns: DefaultGridColumns,
+	}
+}
+
+func (g *PanelGrid) Add(p *Panel) {
+	g.Panels = append(g.Panels, p)
+	g.focused = len(g.Panels) - 1
+	g.layout()
+}
+
+// RemoveFocused removes the focused panel and returns it, if any.
+func (g *PanelGrid) RemoveFocused() *Panel {
+	if len(g.Panels) == 0 {
+		return nil
+	}
+
+	p := g.Panels[g.focused]
+
+	g.Panels = append(g.Panels[:g.focused], g.Panels[g.focused+1:]...)
+	if g.focused >= len(g.Panels) {
+		g.focused = max(0, len(g.Panels)-1)
+	}
+
+	g.layout()
+	return p
+}
+
+func (g *PanelGrid) FocusNext() {
+	if len(g.Panels) > 0 {
+		g.focused = (g.focused + 1) % len(g.Panels)
+	}
+}
+
+func (g *PanelGrid) SetColumns(n int) error {
+	if n < 1 {
+		return fmt.Errorf("invalid number of columns: %d", n)
+	}
+
+	g.Columns = n
+	g.layout()
+	return nil
+}
+
+func (g *PanelGrid) SetRect(x1, y1, x2, y2 int) {
+	g.Block.SetRect(x1, y1, x2, y2)
+	g.layout()
+}
+
+func (g *PanelGrid) layout() {
+	if len(g.Panels) == 0 {
+		return
+	}
+
+	if !slices.ContainsFunc(g.Panels, func(p *Panel) bool { return p.Pos != nil }) {
+		g.flowLayout()
+		return
+	}
+
+	cells := g.Cells()
+
+	cols, rows := g.Columns, 0
+	for _, c := range cells {
+		cols = max(cols, c.Col+c.Width)
+		rows = max(rows, c.Row+c.Height)
+	}
+
+	for i, p := range g.Panels {
+		c := cells[i]
+		p.Rect = image.Rect(
+			g.Min.X+g.Dx()*c.Col/cols, g.Min.Y+g.Dy()*c.Row/rows,
+			g.Min.X+g.Dx()*(c.Col+c.Width)/cols, g.Min.Y+g.Dy()*(c.Row+c.Height)/rows,
+		)
+	}
+}
+
+// flowLayout places panels by row, in the order they were added.
+func (g *PanelGrid) flowLayout() {
+	rows := (len(g.Panels) + g.Columns - 1) / g.Columns
+	for i, p := range g.Panels {
+		row, col := i/g.Columns, i%g.Columns
+
+		cols := min(g.Columns, len(g.Panels)-row*g.Columns)
+
+		p.Rect = image.Rect(
+			g.Min.X+g.Dx()*col/cols, g.Min.Y+g.Dy()*row/rows,
+			g.Min.X+g.Dx()*(col+1)/cols, g.Min.Y+g.Dy()*(row+1)/rows,
+		)
+	}
+}
+
+// Cells returns the cell of each panel. Panels without a position
+// are placed in the first free cell, scanning rows from the top.
+func (g *PanelGrid) Cells() []GridPos {
+	cells := make([]GridPos, len(g.Panels))
+
+	taken := make(map[[2]int]bool)
+	for i, p := range g.Panels {
+		if p.Pos == nil {
+			continue
+		}
+
+		cells[i] = *p.Pos
+		for r := p.Pos.Row; r < p.Pos.Row+p.Pos.Height; r++ {
+			for c := p.Pos.Col; c < p.Pos.Col+p.Pos.Width; c++ {
+				taken[[2]int{r, c}] = true
+			}
+		}
+	}
+
+	next := 0
+	for i, p := range g.Panels {
+		if p.Pos != nil {
+			continue
+		}
+
+		for taken[[2]int{next / g.Columns, next % g.Columns}] {
+			next++
+		}
+
+		cells[i] = GridPos{Row: next / g.Columns, Col: next % g.Columns, Width: 1, Height: 1}
+		taken[[2]int{cells[i].Row, cells[i].Col}] = true
+	}
+	return cells
+}
+
+func (g *PanelGrid) Draw(buf *ui.Buffer) {
+	if len(g.Panels) == 0 {
+		buf.SetString(
+			"No panels: press <C-a> to add the selected metric or query",
+			ui.NewStyle(ui.ColorWhite),
+			image.Pt(g.Min.X+1, g.Min.Y+1),
+		)
+		return
+	}
+
+	for i, p := range g.Panels {
+		d, block := p.widget()
+		d.SetRect(p.Rect.Min.X, p.Rect.Min.Y, p.Rect.Max.X, p.Rect.Max.Y)
+
+		block.BorderStyle.Fg = ui.ColorWhite
+		if i == g.focused {
+			block.BorderStyle.Fg = ui.ColorYellow
+		}
+		d.Draw(buf)
+	}
+}
@@ -56,6 +56,10 @@ func (p *Prompt) OnKeyPressed(key string) bool {
 	case "<C-c>", "<Escape>":
 		p.showExitHint("")
 	default:
+		// other special keys are left to keybindings.
+		if len(key) > 1 && strings.HasPrefix(key, "<") && strings.HasSuffix(key, ">") {
+			return false
+		}
 		p.updateText(key)
 	}
 	return true
@@ -78,17 +82,20 @@ func (p *Prompt) runCommand() {
 		return
 	}
 
-	for key, handler := range p.cmds {
-		if strings.HasPrefix(line, ":"+key) {
-			if err := handler(cmdName, args...); err != nil {
-				p.setError(err)
-				return
-			}
-			return
-		}
+	handler, ok := p.cmds[cmdName]
+	if !ok {
+		p.setError(fmt.Errorf("\"%s\" is not a valid command", cmdName))
+		return
 	}
 
-	p.setError(fmt.Errorf("\"%s\" is not a valid command", cmdName))
+	if err := handler(cmdName, args...); err != nil {
+		p.setError(err)
+	}
+}
+
+// ShowError reports an error which didn't originate from a command, until the next key is pressed.
+func (p *Prompt) ShowError(err error) {
+	p.setError(err)
 }
 
 func (p *Prompt) setError(err error) {
@@ -0,0 +1,77 @@
+package widgets
+
+import (
+	"image"
+
+	ui "github.com/ostafen/termui/v3"
+)
+
+// Stat shows the latest value of one or more series. A single value is
+// drawn at the center of the block, while multiple ones are listed along with their legend.
+type Stat struct {
+	*ui.Block
+
+	Unit   string
+	Legend []string
+	Values []float64
+
+	ValueStyle ui.Style
+	Thresholds []Threshold
+}
+
+func NewStat() *Stat {
+	return &Stat{
+		Block:      ui.NewBlock(),
+		ValueStyle: ui.NewStyle(ui.ColorGreen, ui.ColorClear, ui.ModifierBold),
+	}
+}
+
+func (s *Stat) Draw(buf *ui.Buffer) {
+	s.Block.Draw(buf)
+
+	if len(s.Values) == 0 {
+		s.drawCentered(buf, "no data", ui.NewStyle(ui.ColorWhite))
+		return
+	}
+
+	if len(s.Values) == 1 {
+		s.drawCentered(buf, s.format(s.Values[0]), s.valueStyle(s.Values[0]))
+		return
+	}
+
+	for i, v := range s.Values {
+		y := s.Inner.Min.Y + i
+		if y >= s.Inner.Max.Y {
+			break
+		}
+
+		value := s.format(v)
+
+		name := ""
+		if i < len(s.Legend) {
+			name = ui.TrimString(s.Legend[i], max(0, s.Inner.Dx()-len(value)-1))
+		}
+
+		buf.SetString(name, ui.NewStyle(ui.ColorWhite), image.Pt(s.Inner.Min.X, y))
+		buf.SetString(value, s.valueStyle(v), image.Pt(s.Inner.Max.X-len(value), y))
+	}
+}
+
+func (s *Stat) valueStyle(v float64) ui.Style {
+	style := s.ValueStyle
+	style.Fg = thresholdColor(s.Thresholds, v, style.Fg)
+	return style
+}
+
+func (s *Stat) format(v float64) string {
+	if s.Unit == "" {
+		return formatValue(v)
+	}
+	return formatValue(v) + " " + s.Unit
+}
+
+func (s *Stat) drawCentered(buf *ui.Buffer, text string, style ui.Style) {
+	x := s.Inner.Min.X + max(0, (s.Inner.Dx()-len(text))/2)
+	y := s.Inner.Min.Y + s.Inner.Dy()/2
+	buf.SetString(text, style, image.Pt(x, y))
+}
@@ -0,0 +1,85 @@
+package widgets
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ostafen/termui/v3/widgets"
+)
+
+// TargetStatus describes the last scrape of a target.
+type TargetStatus struct {
+	Name       string
+	LastScrape time.Time
+	Duration   time.Duration
+
+	// Err is set when the last scrape failed, while ParseErrors counts
+	// the malformed lines skipped by the last successful scrape.
+	Err         error
+	ParseErrors int
+	ParseError  error
+}
+
+// StatusBar is a single line showing the health of the targets and the state of
+// the background activities.
+type StatusBar struct {
+	*widgets.Paragraph
+
+	targets []TargetStatus
+	extra   []string
+}
+
+func NewStatusBar() *StatusBar {
+	p := widgets.NewParagraph()
+	p.Border = false
+
+	// the inner area always leaves room for the border, which would
+	// leave no room for text in a single line.
+	p.PaddingTop = -1
+	p.PaddingBottom = -1
+
+	return &StatusBar{Paragraph: p}
+}
+
+// SetTargets sets the status of each target, in the order they are shown.
+func (sb *StatusBar) SetTargets(targets []TargetStatus) {
+	sb.targets = targets
+	sb.update()
+}
+
+// SetExtra sets the items shown after the status of the targets.
+func (sb *StatusBar) SetExtra(items ...string) {
+	sb.extra = items
+	sb.update()
+}
+
+func (sb *StatusBar) update() {
+	items := make([]string, 0, len(sb.targets)+len(sb.extra))
+	for _, t := range sb.targets {
+		items = append(items, formatTargetStatus(&t))
+	}
+
+	items = append(items, sb.extra...)
+	sb.Text = strings.Join(items, " | ")
+}
+
+func formatTargetStatus(t *TargetStatus) string {
+	if t.LastScrape.IsZero() {
+		return fmt.Sprintf("[●](fg:white) %s: pending", t.Name)
+	}
+
+	s := fmt.Sprintf("%s %s %s", t.Name, t.LastScrape.Format(time.TimeOnly), t.Duration.Round(time.Millisecond))
+	switch {
+	case t.Err != nil:
+		return fmt.Sprintf("[●](fg:red) %s [%s](fg:red)", s, escapeMarkup(t.Err.Error()))
+	case t.ParseErrors > 0:
+		return fmt.Sprintf("[●](fg:yellow) %s [malformed lines: %d (%s)](fg:yellow)", s, t.ParseErrors, escapeMarkup(t.ParseError.Error()))
+	}
+	return fmt.Sprintf("[●](fg:green) %s", s)
+}
+
+// escapeMarkup replaces the brackets of a text, which would be taken for style markup.
+func escapeMarkup(s string) string {
+	return strings.NewReplacer("[", "(", "]", ")").Replace(s)
+}
@@ -0,0 +1,58 @@
+package widgets
+
+import (
+	"fmt"
+	"sort"
+
+	ui "github.com/ostafen/termui/v3"
+)
+
+// Threshold colors the values greater than or equal to Value.
+type Threshold struct {
+	Value float64
+	Color ui.Color
+}
+
+var colorNames = map[string]ui.Color{
+	"black":   ui.ColorBlack,
+	"red":     ui.ColorRed,
+	"green":   ui.ColorGreen,
+	"yellow":  ui.ColorYellow,
+	"blue":    ui.ColorBlue,
+	"magenta": ui.ColorMagenta,
+	"cyan":    ui.ColorCyan,
+	"white":   ui.ColorWhite,
+}
+
+func ParseColor(name string) (ui.Color, error) {
+	c, ok := colorNames[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown color \"%s\"", name)
+	}
+	return c, nil
+}
+
+func ColorName(c ui.Color) string {
+	for name, color := range colorNames {
+		if color == c {
+			return name
+		}
+	}
+	return ""
+}
+
+// thresholdColor returns the color of the highest threshold not above v.
+func thresholdColor(thresholds []Threshold, v float64, defaultColor ui.Color) ui.Color {
+	sorted := append([]Threshold(nil), thresholds...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Value < sorted[j].Value
+	})
+
+	color := defaultColor
+	for _, t := range sorted {
+		if v >= t.Value {
+			color = t.Color
+		}
+	}
+	return color
+}